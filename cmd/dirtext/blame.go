@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// authorMarker starts each commit's author in the git log output read by
+// gitLastAuthors, so it can't be mistaken for a path
+const authorMarker = "\x01"
+
+// annotateAuthors notes the author of the last commit that touched each file
+// in the tree. Files git doesn't track get no note.
+func annotateAuthors(rootDir string, root *Node) error {
+	var paths []string
+	walkNodes(root, func(n *Node) {
+		if !n.IsDir {
+			paths = append(paths, n.Path)
+		}
+	})
+
+	authors, err := gitLastAuthors(rootDir, paths)
+	if err != nil {
+		return err
+	}
+
+	walkNodes(root, func(n *Node) {
+		if author, ok := authors[n.Path]; ok && !n.IsDir {
+			n.Notes = append(n.Notes, "by "+author)
+		}
+	})
+	return nil
+}
+
+// gitLastAuthors finds the last author of each of the paths, relative to
+// rootDir, with a single git log walking back from HEAD. The log is stopped
+// as soon as every path has been seen, rather than running one git command
+// per file.
+func gitLastAuthors(rootDir string, paths []string) (map[string]string, error) {
+	authors := make(map[string]string)
+	if len(paths) == 0 {
+		return authors, nil
+	}
+
+	wanted := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		wanted[p] = true
+	}
+
+	// Each commit is printed as the marked author name followed by the
+	// paths it changed, relative to rootDir, all NUL-terminated
+	cmd := exec.Command("git", "log", "-z", "--relative", "--name-only", "--format="+authorMarker+"%an", "--", ".")
+	cmd.Dir = rootDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+
+	r := bufio.NewReader(stdout)
+	author, afterAuthor := "", false
+	for len(wanted) > 0 {
+		token, err := r.ReadString(0)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return nil, err
+		}
+		token = strings.TrimSuffix(token, "\x00")
+
+		// The first path of a commit follows a newline after its author
+		if afterAuthor {
+			token = strings.TrimPrefix(token, "\n")
+		}
+
+		if name, ok := strings.CutPrefix(token, authorMarker); ok {
+			author, afterAuthor = name, true
+			continue
+		}
+		afterAuthor = false
+
+		if wanted[token] {
+			authors[token] = author
+			delete(wanted, token)
+		}
+	}
+
+	// Stop git once every path has an author
+	if len(wanted) == 0 {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return authors, nil
+	}
+
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return nil, fmt.Errorf("git log: %s", strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	return authors, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// drawBox frames a rendered text tree in a box-drawing border, moving its
+// first line, the root's name, into the title bar:
+//
+//	┌─ module ───┐
+//	│ ├── cmd    │
+//	│ └── go.mod │
+//	└────────────┘
+//
+// Widths are measured in terminal columns, and tabs are expanded so they
+// don't break the right-hand border.
+func drawBox(tree []byte) []byte {
+	lines := strings.Split(strings.TrimSuffix(string(tree), "\n"), "\n")
+	title, body := lines[0], lines[1:]
+	for i, line := range body {
+		body[i] = strings.ReplaceAll(line, "\t", "    ")
+	}
+
+	// The box is as wide as the longest line, or the title bar if that is
+	// wider
+	width := displayWidth(title) + 2
+	for _, line := range body {
+		width = max(width, displayWidth(line))
+	}
+
+	var b bytes.Buffer
+	b.WriteString("┌─ " + title + " " + strings.Repeat("─", width-displayWidth(title)-1) + "┐\n")
+	for _, line := range body {
+		b.WriteString("│ " + line + strings.Repeat(" ", width-displayWidth(line)) + " │\n")
+	}
+	b.WriteString("└" + strings.Repeat("─", width+2) + "┘\n")
+
+	return b.Bytes()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// racyWindow is how recently a directory may have been modified and still be
+// cached. A change made within the same timestamp tick as the scan would not
+// alter the directory's mtime, so such directories are always re-read.
+const racyWindow = 2 * time.Second
+
+// cachedDir is the saved listing of one directory
+type cachedDir struct {
+	// ModTime is the directory's mtime in nanoseconds when it was listed
+	ModTime int64         `json:"modTime"`
+	Entries []cachedEntry `json:"entries"`
+}
+
+// cachedEntry is one name in a cached listing. Only the name and type are
+// cached, because those are exactly what a directory's mtime protects; sizes
+// and other metadata are always read fresh.
+type cachedEntry struct {
+	Name string      `json:"name"`
+	Type fs.FileMode `json:"type"`
+}
+
+// scanCache reuses directory listings between runs for directories whose
+// mtime hasn't changed
+type scanCache struct {
+	path string
+
+	// old holds the listings loaded from disk and next those seen in this
+	// scan, so directories that disappeared are dropped when saving
+	old  map[string]cachedDir
+	next map[string]cachedDir
+	hits int
+}
+
+// openScanCache loads the cache for rootDir from cacheDir. A missing or
+// unreadable cache file just starts an empty cache.
+func openScanCache(cacheDir, rootDir string) *scanCache {
+	sum := sha256.Sum256([]byte(rootDir))
+	c := &scanCache{
+		path: filepath.Join(cacheDir, hex.EncodeToString(sum[:8])+".json"),
+		old:  make(map[string]cachedDir),
+		next: make(map[string]cachedDir),
+	}
+
+	if data, err := os.ReadFile(c.path); err == nil {
+		if err := json.Unmarshal(data, &c.old); err != nil {
+			c.old = make(map[string]cachedDir)
+		}
+	}
+
+	return c
+}
+
+// readDir lists a directory, using the cached listing if the directory's
+// mtime still matches
+func (c *scanCache) readDir(path string) ([]fs.DirEntry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	modTime := info.ModTime().UnixNano()
+
+	if cached, ok := c.old[path]; ok && cached.ModTime == modTime {
+		c.hits++
+		c.next[path] = cached
+		return cached.dirEntries(path), nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Since(info.ModTime()) > racyWindow {
+		cached := cachedDir{ModTime: modTime}
+		for _, e := range entries {
+			cached.Entries = append(cached.Entries, cachedEntry{Name: e.Name(), Type: e.Type()})
+		}
+		c.next[path] = cached
+	}
+
+	return entries, nil
+}
+
+// save writes the listings seen in this scan to the cache file, replacing it
+// atomically so a concurrent run never reads a partial file
+func (c *scanCache) save() error {
+	data, err := json.Marshal(c.next)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".dirtext-cache-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// defaultCacheDir returns the directory used when -cache-dir isn't given
+func defaultCacheDir() string {
+	return filepath.Join(os.TempDir(), "dirtext-cache")
+}
+
+// dirEntries converts the cached listing of dir back to fs.DirEntry values
+func (d cachedDir) dirEntries(dir string) []fs.DirEntry {
+	entries := make([]fs.DirEntry, len(d.Entries))
+	for i, e := range d.Entries {
+		entries[i] = cachedDirEntry{name: e.Name, typ: e.Type, dir: dir}
+	}
+	return entries
+}
+
+// cachedDirEntry implements fs.DirEntry for a cached name, reading the
+// entry's metadata from disk on demand
+type cachedDirEntry struct {
+	name string
+	typ  fs.FileMode
+	dir  string
+}
+
+func (e cachedDirEntry) Name() string      { return e.name }
+func (e cachedDirEntry) IsDir() bool       { return e.typ.IsDir() }
+func (e cachedDirEntry) Type() fs.FileMode { return e.typ }
+
+func (e cachedDirEntry) Info() (fs.FileInfo, error) {
+	return os.Lstat(filepath.Join(e.dir, e.name))
+}
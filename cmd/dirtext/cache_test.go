@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setDirTimes sets the mtime of root and every directory beneath it
+func setDirTimes(t *testing.T, root string, mtime time.Time) {
+	t.Helper()
+
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		return os.Chtimes(path, mtime, mtime)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanCacheNeverStale(t *testing.T) {
+	root := makeTree(t, "a.txt", "dir/b.txt", "dir/sub/c.txt")
+	cacheDir := t.TempDir()
+	scan := func() (string, string) {
+		t.Helper()
+		stdout, stderr, err := runDirtext(t, "-cache-dir", cacheDir, "-timing", root)
+		if err != nil {
+			t.Fatalf("%v\n%s", err, stderr)
+		}
+		return stdout, stderr
+	}
+
+	// Directories last changed long ago are cached and then reused
+	old := time.Now().Add(-time.Hour)
+	setDirTimes(t, root, old)
+	first, _ := scan()
+	second, timing := scan()
+	if second != first {
+		t.Errorf("cached scan differs:\n%s\nwant:\n%s", second, first)
+	}
+	if !strings.Contains(timing, "(3 from cache)") {
+		t.Errorf("want every directory from the cache, got %q", timing)
+	}
+
+	// Adding, removing and renaming entries changes the directory's mtime,
+	// so the new listing is read
+	writeFile(t, root, "dir/new.txt", "")
+	if err := os.Remove(filepath.Join(root, "dir", "sub", "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(root, "a.txt"), filepath.Join(root, "z.txt")); err != nil {
+		t.Fatal(err)
+	}
+	want := lines(
+		"root",
+		"├── dir",
+		"│   ├── b.txt",
+		"│   ├── new.txt",
+		"│   └── sub",
+		"└── z.txt",
+	)
+	if got, _ := scan(); got != want {
+		t.Errorf("after changes:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestScanCacheRacyWindow(t *testing.T) {
+	root := makeTree(t, "dir/a.txt")
+	cacheDir := t.TempDir()
+
+	// A directory changed within racyWindow of the scan isn't cached, so a
+	// change later in the same timestamp tick, which leaves the mtime as it
+	// was, is still seen
+	recent := time.Now().Add(-racyWindow / 2)
+	setDirTimes(t, root, recent)
+	render(t, "-cache-dir", cacheDir, root)
+
+	writeFile(t, root, "dir/b.txt", "")
+	setDirTimes(t, root, recent)
+
+	want := lines(
+		"root",
+		"└── dir",
+		"    ├── a.txt",
+		"    └── b.txt",
+	)
+	if got := render(t, "-cache-dir", cacheDir, root); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// caseCollision is a set of entries in one directory whose names differ only
+// in case, so they can't all exist on a case-insensitive filesystem
+type caseCollision struct {
+	// Dir is the directory's path relative to the scan root, "" for the root
+	Dir   string
+	Names []string
+}
+
+// findCaseCollisions returns the collisions in every directory of the tree,
+// in display order
+func findCaseCollisions(root *Node) []caseCollision {
+	var collisions []caseCollision
+	walkNodes(root, func(n *Node) {
+		byFolded := make(map[string][]string)
+		var order []string
+		for _, child := range n.Children {
+			folded := strings.ToLower(child.Name)
+			if _, seen := byFolded[folded]; !seen {
+				order = append(order, folded)
+			}
+			byFolded[folded] = append(byFolded[folded], child.Name)
+		}
+
+		for _, folded := range order {
+			if names := byFolded[folded]; len(names) > 1 {
+				sort.Strings(names)
+				collisions = append(collisions, caseCollision{Dir: n.Path, Names: names})
+			}
+		}
+	})
+	return collisions
+}
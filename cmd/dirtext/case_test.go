@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCaseCollisions(t *testing.T) {
+	root := makeTree(t, "README.md", "ReadMe.md", "src/A/", "src/a", "x/File", "x/file", "x/FILE", "uniq", "build.log", "BUILD.LOG")
+	writeFile(t, root, ".gitignore", "build.log\n")
+
+	// Each set once, sorted, with ignored entries left out
+	want := lines(
+		"Warning: names in . differ only in case: README.md, ReadMe.md",
+		"Warning: names in src differ only in case: A, a",
+		"Warning: names in x differ only in case: FILE, File, file",
+	)
+	stdout, stderr, err := runDirtext(t, "-check-case-collisions", root)
+	if err != nil || stderr != want {
+		t.Errorf("stderr:\n%s\nwant:\n%s\nerror %v", stderr, want, err)
+	}
+	if !strings.Contains(stdout, "ReadMe.md") {
+		t.Errorf("no tree:\n%s", stdout)
+	}
+
+	// -strict fails after writing the tree
+	stdout, _, err = runDirtext(t, "-check-case-collisions", "-strict", root)
+	if err == nil || err.Error() != "3 case collisions found" {
+		t.Errorf("-strict: error %v", err)
+	}
+	if !strings.Contains(stdout, "ReadMe.md") {
+		t.Errorf("-strict: no tree:\n%s", stdout)
+	}
+
+	// Filters decide what is checked
+	if _, stderr, err := runDirtext(t, "-check-case-collisions", "-strict", "-exclude", "/{ReadMe.md,src,x}", root); err != nil || stderr != "" {
+		t.Errorf("filtered: %v\n%s", err, stderr)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Statuses of entries in a -compare tree, as seen from the scan root: added
+// entries exist only in the root, removed ones only in the compared
+// directory, and changed ones in both but with different contents or types.
+const (
+	statusAdded   = "added"
+	statusRemoved = "removed"
+	statusChanged = "changed"
+	statusCommon  = "common"
+)
+
+// compareTrees merges the scanned tree with the tree of the directory it is
+// compared against, setting each node's Status and noting it for the text
+// output. Files count as changed when their hashes differ if both were
+// hashed, and otherwise when their sizes or modification times differ.
+func compareTrees(root, other *Node) *Node {
+	merged := *root
+	merged.Status = statusCommon
+	merged.Children = mergeChildren(root.Children, other.Children)
+	return &merged
+}
+
+// mergeChildren merges two child lists sorted by name
+func mergeChildren(ours, theirs []*Node) []*Node {
+	byName := make(map[string]*Node, len(theirs))
+	for _, n := range theirs {
+		byName[n.Name] = n
+	}
+
+	var merged []*Node
+	for _, n := range ours {
+		other, ok := byName[n.Name]
+		if !ok {
+			merged = append(merged, markStatus(n, statusAdded))
+			continue
+		}
+		delete(byName, n.Name)
+
+		if n.IsDir && other.IsDir {
+			dir := *n
+			dir.Status = statusCommon
+			dir.Children = mergeChildren(n.Children, other.Children)
+			merged = append(merged, &dir)
+			continue
+		}
+
+		status := statusCommon
+		if changed(n, other) {
+			status = statusChanged
+		}
+		merged = append(merged, markStatus(n, status))
+	}
+
+	// Entries only in the compared directory keep their name order
+	for _, n := range theirs {
+		if _, ok := byName[n.Name]; ok {
+			merged = append(merged, markStatus(n, statusRemoved))
+		}
+	}
+
+	sortChildrenByName(merged)
+	return merged
+}
+
+// changed reports whether two entries with the same path differ
+func changed(a, b *Node) bool {
+	if a.IsDir != b.IsDir || a.Mode.Type() != b.Mode.Type() {
+		return true
+	}
+	if a.Hash != "" && b.Hash != "" {
+		return a.Hash != b.Hash
+	}
+	return a.Size != b.Size || !a.ModTime.Equal(b.ModTime)
+}
+
+// markStatus sets the status of n and everything beneath it
+func markStatus(n *Node, status string) *Node {
+	marked := *n
+	marked.Status = status
+	if status != statusCommon {
+		marked.Notes = append(append([]string(nil), n.Notes...), status)
+	}
+
+	marked.Children = nil
+	for _, child := range n.Children {
+		marked.Children = append(marked.Children, markStatus(child, status))
+	}
+
+	return &marked
+}
+
+// keepChanges prunes a compared tree down to its added, removed and changed
+// entries and the directories holding them
+func keepChanges(root *Node) {
+	dropCommonFiles(root)
+	pruneEmptyDirs(root, func(n *Node) bool {
+		return n.Status != statusCommon
+	})
+}
+
+// dropCommonFiles removes the files, and symlinks, that are the same in both
+// trees
+func dropCommonFiles(n *Node) {
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		if child.IsDir {
+			dropCommonFiles(child)
+		} else if child.Status == statusCommon {
+			continue
+		}
+		children = append(children, child)
+	}
+	n.Children = children
+}
+
+// diffEntry is one entry of -diff-format json output
+type diffEntry struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+}
+
+// renderDiffJSON writes every entry of a compared tree with its status as a
+// JSON array
+func renderDiffJSON(w io.Writer, root *Node) error {
+	entries := []diffEntry{}
+	walkNodes(root, func(n *Node) {
+		if n != root && !n.Filtered {
+			entries = append(entries, diffEntry{Path: n.Path, Status: n.Status})
+		}
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
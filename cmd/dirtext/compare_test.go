@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// compareFixture returns two trees holding an entry of each -compare status.
+// "touched" has the same contents on both sides but a different mtime, so
+// only -hash sees it as common.
+func compareFixture(t *testing.T) (root, old string) {
+	t.Helper()
+
+	root = makeTree(t, "dir/")
+	old = makeTree(t, "dir/")
+	for name, contents := range map[string][2]string{
+		"same":     {"a\n", "a\n"},
+		"touched":  {"q\n", "q\n"},
+		"dir/edit": {"one\n", "two\n"},
+	} {
+		writeFile(t, root, name, contents[0])
+		writeFile(t, old, name, contents[1])
+	}
+	writeFile(t, root, "added", "x\n")
+	writeFile(t, old, "removed", "y\n")
+
+	stamp := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, path := range []string{
+		filepath.Join(root, "same"), filepath.Join(old, "same"),
+		filepath.Join(root, "touched"),
+		filepath.Join(root, "dir", "edit"), filepath.Join(old, "dir", "edit"),
+	} {
+		if err := os.Chtimes(path, stamp, stamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(filepath.Join(old, "touched"), stamp.AddDate(1, 0, 0), stamp.AddDate(1, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	return root, old
+}
+
+func TestCompareStatuses(t *testing.T) {
+	root, old := compareFixture(t)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// Without -hash, "dir/edit" is the same size and mtime on both sides
+		{nil, lines(
+			"root",
+			"├── added (added)",
+			"├── dir",
+			"│   └── edit",
+			"├── removed (removed)",
+			"├── same",
+			"└── touched (changed)",
+		)},
+		{[]string{"-hash"}, lines(
+			"root",
+			"├── added (sha256:73cb3858a687) (added)",
+			"├── dir",
+			"│   └── edit (sha256:2c8b08da5ce6) (changed)",
+			"├── removed (sha256:3bb2abb69ebb) (removed)",
+			"├── same (sha256:87428fc52280)",
+			"└── touched (sha256:4adc33bd9fe7)",
+		)},
+		{[]string{"-hash", "-diff-only"}, lines(
+			"root",
+			"├── added (sha256:73cb3858a687) (added)",
+			"├── dir",
+			"│   └── edit (sha256:2c8b08da5ce6) (changed)",
+			"└── removed (sha256:3bb2abb69ebb) (removed)",
+		)},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-compare", old}, tt.args...)
+		if got := render(t, append(args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCompareDiffJSON(t *testing.T) {
+	root, old := compareFixture(t)
+
+	tests := []struct {
+		args []string
+		want []diffEntry
+	}{
+		{nil, []diffEntry{
+			{"added", statusAdded},
+			{"dir", statusCommon},
+			{"dir/edit", statusCommon},
+			{"removed", statusRemoved},
+			{"same", statusCommon},
+			{"touched", statusChanged},
+		}},
+		{[]string{"-hash"}, []diffEntry{
+			{"added", statusAdded},
+			{"dir", statusCommon},
+			{"dir/edit", statusChanged},
+			{"removed", statusRemoved},
+			{"same", statusCommon},
+			{"touched", statusCommon},
+		}},
+		{[]string{"-hash", "-diff-only"}, []diffEntry{
+			{"added", statusAdded},
+			{"dir", statusCommon},
+			{"dir/edit", statusChanged},
+			{"removed", statusRemoved},
+		}},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-compare", old, "-diff-format", "json"}, tt.args...)
+		var got []diffEntry
+		if err := json.Unmarshal([]byte(render(t, append(args, root)...)), &got); err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v:\ngot  %v\nwant %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestDiffOnly(t *testing.T) {
+	root := makeTree(t, "add/n", "chg/old/", "keep/deep/k", "same/x")
+	old := makeTree(t, "chg/old", "gone/z", "keep/deep/k", "same/x")
+	writeFile(t, root, "chg/h", "a\n")
+	writeFile(t, old, "chg/h", "bb\n")
+	stamp := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, dir := range []string{root, old} {
+		for _, name := range []string{"keep/deep/k", "same/x"} {
+			if err := os.Chtimes(filepath.Join(dir, name), stamp, stamp); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Added and removed directories keep their contents, and a common
+	// directory stays only while something beneath it differs
+	want := lines(
+		"root",
+		"├── add (added)",
+		"│   └── n (added)",
+		"├── chg",
+		"│   ├── h (changed)",
+		"│   └── old (changed)",
+		"└── gone (removed)",
+		"    └── z (removed)",
+	)
+	if got := render(t, "-compare", old, "-diff-only", root); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Identical trees leave nothing to show
+	stdout, stderr, err := runDirtext(t, "-compare", old, "-diff-only", "-diff-format", "json", old)
+	if err != nil || stdout != "[]\n" || stderr != lines("Note: root has no matching entries") {
+		t.Errorf("identical trees: stdout %q, stderr %q, error %v", stdout, stderr, err)
+	}
+
+	if _, _, err := runDirtext(t, "-diff-only", root); err == nil {
+		t.Error("-diff-only without -compare was accepted")
+	}
+}
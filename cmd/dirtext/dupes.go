@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// dupeGroup is a set of files with identical contents
+type dupeGroup struct {
+	size  int64
+	paths []string
+}
+
+// findDuplicates groups the visible regular files of the tree by content.
+// Files are first bucketed by size so only possible duplicates are hashed.
+// Empty files are skipped since removing them saves nothing.
+func findDuplicates(rootDir string, root *Node) ([]dupeGroup, error) {
+	bySize := filesBySize(root)
+
+	sums, err := hashFiles(rootDir, dupeCandidates(bySize))
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		size int64
+		sum  string
+	}
+	byContent := make(map[key][]string)
+	for size, paths := range bySize {
+		for _, path := range paths {
+			if sum, ok := sums[path]; ok {
+				k := key{size, sum}
+				byContent[k] = append(byContent[k], path)
+			}
+		}
+	}
+
+	var groups []dupeGroup
+	for k, paths := range byContent {
+		if len(paths) > 1 {
+			sort.Strings(paths)
+			groups = append(groups, dupeGroup{size: k.size, paths: paths})
+		}
+	}
+
+	// Largest groups first, then by path so the output is stable
+	sort.Slice(groups, func(i, j int) bool {
+		wi, wj := groups[i].wasted(), groups[j].wasted()
+		if wi != wj {
+			return wi > wj
+		}
+		return groups[i].paths[0] < groups[j].paths[0]
+	})
+
+	return groups, nil
+}
+
+// wasted returns the bytes that could be reclaimed by keeping one copy
+func (g dupeGroup) wasted() int64 {
+	return g.size * int64(len(g.paths)-1)
+}
+
+// renderDupes writes each duplicate group followed by the potential savings
+func renderDupes(w io.Writer, groups []dupeGroup) {
+	var savings int64
+	for _, g := range groups {
+		fmt.Fprintf(w, "%d files, %s each:\n", len(g.paths), formatSize(g.size))
+		for _, path := range g.paths {
+			fmt.Fprintf(w, "  %s\n", path)
+		}
+		savings += g.wasted()
+	}
+
+	fmt.Fprintf(w, "Potential savings: %s across %d duplicate groups\n",
+		formatSize(savings), len(groups))
+}
+
+// formatSize formats a byte count using binary units, e.g. "4.2 MiB"
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// filesBySize groups the paths of the non-empty regular files by size
+func filesBySize(root *Node) map[int64][]string {
+	bySize := make(map[int64][]string)
+	walkNodes(root, func(n *Node) {
+		if n != root && n.Mode.IsRegular() && n.Size > 0 {
+			bySize[n.Size] = append(bySize[n.Size], n.Path)
+		}
+	})
+	return bySize
+}
+
+// dupeCandidates returns the paths of the files findDuplicates hashes: those
+// sharing their size with another file
+func dupeCandidates(bySize map[int64][]string) []string {
+	var candidates []string
+	for _, paths := range bySize {
+		if len(paths) > 1 {
+			candidates = append(candidates, paths...)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// pathEntry describes one entry of a tree that doesn't come from walking the
+// disk, such as a file inside an image archive
+type pathEntry struct {
+	// Path is relative to the tree's root and uses '/'
+	Path       string
+	Mode       fs.FileMode
+	Size       int64
+	ModTime    time.Time
+	LinkTarget string
+
+	// Missing marks tracked paths that don't exist on disk
+	Missing bool
+
+	// OutsideRoot marks symlinks leading out of the root, noted instead of
+	// showing their targets with -no-escape-root
+	OutsideRoot bool
+}
+
+// treeFromEntries builds a tree named name from a flat list of entries,
+// applying the same filters as a walk of the disk. rootDir is the directory
+// the entries are relative to, or empty if they aren't on disk. Parent
+// directories that aren't listed themselves are created as needed.
+func treeFromEntries(name, rootDir string, entries []pathEntry, opts *Options) (*Node, error) {
+	filter, err := newEntryFilter(opts, rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &Node{Name: name, IsDir: true, Mode: fs.ModeDir}
+	dirs := map[string]*Node{"": root}
+
+	// dir returns the node for a directory, creating it and its parents
+	var dir func(p string) *Node
+	dir = func(p string) *Node {
+		if n, ok := dirs[p]; ok {
+			return n
+		}
+		parent := dir(parentDir(p))
+		n := &Node{Name: path.Base(p), Path: p, IsDir: true, Mode: fs.ModeDir}
+		parent.Children = append(parent.Children, n)
+		dirs[p] = n
+		return n
+	}
+
+	for _, e := range entries {
+		isDir := e.Mode.IsDir()
+
+		// Hidden directories hide everything beneath them, just like on disk
+		if filter.hidden(e.Path, isDir) {
+			continue
+		}
+		if filter.excluded(e.Path, e.Mode) {
+			continue
+		}
+
+		// Leaf directories are shown without their contents
+		if leaf := filter.leafAncestor(e.Path, isDir); leaf != "" {
+			dir(leaf).Collapsed = true
+			continue
+		}
+
+		if isDir {
+			dir(e.Path).Mode = e.Mode
+			continue
+		}
+
+		node := &Node{
+			Name:    path.Base(e.Path),
+			Path:    e.Path,
+			Mode:    e.Mode,
+			Size:    e.Size,
+			ModTime: e.ModTime,
+		}
+		if e.OutsideRoot {
+			node.Notes = append(node.Notes, "outside root")
+		}
+		if opts.LinkTargets {
+			node.LinkTarget = e.LinkTarget
+		}
+		if e.Missing {
+			node.Missing = true
+			node.Notes = append(node.Notes, "not checked out")
+		}
+
+		parent := dir(parentDir(e.Path))
+		parent.Children = append(parent.Children, node)
+	}
+
+	// Directories are kept to hold their children even when the type
+	// filter excludes them, as on disk
+	for p, n := range dirs {
+		n.Filtered = p != "" && filter.hidesDir(fs.ModeDir)
+	}
+
+	filter.prune(root)
+
+	sortByName(root)
+
+	return root, nil
+}
+
+// sortByName sorts every directory's children by name, matching the order of
+// a walk of the disk
+func sortByName(n *Node) {
+	sortChildrenByName(n.Children)
+	for _, child := range n.Children {
+		sortByName(child)
+	}
+}
+
+// sortChildrenByName sorts one list of children by name
+func sortChildrenByName(children []*Node) {
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name < children[j].Name
+	})
+}
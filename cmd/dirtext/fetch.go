@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// fetchRepo shallow-clones the git repository at url into a new temporary
+// directory, returning the clone's path and a function that removes it
+func fetchRepo(url string) (string, func(), error) {
+	tmpDir, err := os.MkdirTemp("", "dirtext-fetch-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmpDir) }
+
+	// Name the clone after the repository, so the tree is too
+	name := strings.TrimSuffix(path.Base(strings.TrimRight(url, "/")), ".git")
+	if name == "" || name == "." || name == "/" {
+		name = "repo"
+	}
+	cloneDir := filepath.Join(tmpDir, name)
+
+	// "--" keeps a URL starting with a dash from being read as an option
+	if _, err := gitOutput(tmpDir, "clone", "--depth", "1", "--quiet", "--", url, cloneDir); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return cloneDir, cleanup, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	root := makeTree(t, "cmd/main.go", "README.md", "scratch.txt")
+	repo := filepath.Join(filepath.Dir(root), "project")
+	if err := os.Rename(root, repo); err != nil {
+		t.Fatal(err)
+	}
+	initRepo(t, repo, "scratch.txt")
+
+	// The clone goes in TMPDIR and is removed afterwards
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	left := func() []os.DirEntry {
+		t.Helper()
+		entries, err := os.ReadDir(tmp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entries
+	}
+
+	// Only what was committed is fetched, and the tree is named after the
+	// repository
+	want := lines(
+		"project",
+		"├── README.md",
+		"└── cmd",
+		"    └── main.go",
+	)
+	if got := render(t, "-fetch", "file://"+filepath.ToSlash(repo)); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if entries := left(); len(entries) != 0 {
+		t.Errorf("clone left behind: %v", entries)
+	}
+
+	if _, _, err := runDirtext(t, "-fetch", "file://"+filepath.ToSlash(filepath.Join(tmp, "missing"))); err == nil {
+		t.Error("fetching a missing repository succeeded")
+	}
+	if entries := left(); len(entries) != 0 {
+		t.Errorf("failed clone left behind: %v", entries)
+	}
+
+	if _, _, err := runDirtext(t, "-fetch", "file://"+filepath.ToSlash(repo), repo); err == nil {
+		t.Error("-fetch with a root was accepted")
+	}
+}
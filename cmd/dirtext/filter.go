@@ -0,0 +1,500 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// typeLetters maps the find(1)-style -type-filter letters to the file mode
+// type bits they select. Regular files have no type bits set.
+var typeLetters = map[rune]fs.FileMode{
+	'f': 0,
+	'd': fs.ModeDir,
+	'l': fs.ModeSymlink,
+}
+
+// parseTypeFilter converts a -type-filter value such as "fl" into the set of
+// allowed file mode type bits. An empty value allows every type.
+func parseTypeFilter(value string) (map[fs.FileMode]bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	allowed := make(map[fs.FileMode]bool)
+	for _, letter := range value {
+		mode, ok := typeLetters[letter]
+		if !ok {
+			return nil, fmt.Errorf("invalid -type-filter letter %q (want f, d or l)", letter)
+		}
+		allowed[mode] = true
+	}
+
+	return allowed, nil
+}
+
+// allowedType reports whether an entry with the given mode passes the type
+// filter
+func allowedType(mode fs.FileMode, allowed map[fs.FileMode]bool) bool {
+	if allowed == nil {
+		return true
+	}
+	return allowed[mode&fs.ModeType]
+}
+
+// specialTypes are the file mode type bits for entries that aren't regular
+// files, directories or symlinks
+const specialTypes = fs.ModeSocket | fs.ModeDevice | fs.ModeCharDevice | fs.ModeNamedPipe | fs.ModeIrregular
+
+// isSpecial checks if an entry with the given mode is a socket, device, named
+// pipe or other irregular file
+func isSpecial(mode fs.FileMode) bool {
+	return mode&specialTypes != 0
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// expandAllBraces brace-expands each of the patterns
+func expandAllBraces(patterns []string) []string {
+	var expanded []string
+	for _, pattern := range patterns {
+		expanded = append(expanded, expandBraces(pattern)...)
+	}
+	return expanded
+}
+
+// expandBraces expands shell-style alternatives, so "*.{go,md}" becomes
+// "*.go" and "*.md". Braces may nest, and an empty alternative is kept, so
+// "a{,b}" gives "a" and "ab". As in the shell, braces without a comma at
+// their own level, or without a closing brace, are taken literally.
+func expandBraces(pattern string) []string {
+	for lbrace := 0; lbrace < len(pattern); lbrace++ {
+		if pattern[lbrace] != '{' {
+			continue
+		}
+
+		// Find the matching close brace and the commas at this level
+		depth, commas := 0, []int{}
+		rbrace := -1
+		for i := lbrace; i < len(pattern) && rbrace < 0; i++ {
+			switch pattern[i] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					rbrace = i
+				}
+			case ',':
+				if depth == 1 {
+					commas = append(commas, i)
+				}
+			}
+		}
+		if rbrace < 0 || len(commas) == 0 {
+			continue
+		}
+
+		prefix, suffix := pattern[:lbrace], pattern[rbrace+1:]
+		var expanded []string
+		start := lbrace + 1
+		for _, end := range append(commas, rbrace) {
+			expanded = append(expanded, expandBraces(prefix+pattern[start:end]+suffix)...)
+			start = end + 1
+		}
+		return expanded
+	}
+
+	return []string{pattern}
+}
+
+// matchesAny checks if a path, or any of its parent directories, matches one
+// of the patterns. Patterns use the gitignore syntax, so a leading slash
+// anchors them to the scan root.
+func matchesAny(path string, isDir bool, patterns []string) bool {
+	for _, pattern := range patterns {
+		if match(path, pattern, isDir) || matchesBase(path, pattern) {
+			return true
+		}
+	}
+
+	// A matching directory includes everything beneath it
+	for dir := parentDir(path); dir != ""; dir = parentDir(dir) {
+		for _, pattern := range patterns {
+			if match(dir, pattern, true) || matchesBase(dir, pattern) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchesAbs checks if an absolute path matches one of the -exclude-abs
+// patterns. Patterns are absolute globs that must match the whole path, so
+// they're matched as anchored patterns from the filesystem root.
+func matchesAbs(absPath string, isDir bool, patterns []string) bool {
+	path := strings.TrimPrefix(filepath.ToSlash(absPath), "/")
+	for _, pattern := range patterns {
+		if match(path, "/"+strings.TrimPrefix(filepath.ToSlash(pattern), "/"), isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesBase checks if a pattern without a slash matches the base name of
+// a path, so "*.go" matches "main.go" at any depth
+func matchesBase(path, pattern string) bool {
+	if strings.Contains(pattern, "/") {
+		return false
+	}
+	matched, _ := filepath.Match(pattern, filepath.Base(path))
+	return matched
+}
+
+// pruneEmptyDirs removes directories that have no children left and aren't
+// wanted in their own right, as decided by keep
+func pruneEmptyDirs(n *Node, keep func(*Node) bool) {
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		if child.IsDir {
+			pruneEmptyDirs(child, keep)
+			if len(child.Children) == 0 && !keep(child) {
+				continue
+			}
+		}
+		children = append(children, child)
+	}
+	n.Children = children
+}
+
+// entryFilter holds the command line filters. The same filter is applied to
+// walks of the disk and to path lists such as -git-tracked output, so every
+// source of entries behaves alike.
+type entryFilter struct {
+	types       map[fs.FileMode]bool
+	include     []string
+	exclude     []string
+	exts        []string
+	binaryExts  []string
+	skipSpecial bool
+
+	// rootDir is the absolute scan root that -exclude-abs patterns are
+	// matched under. It is empty for trees that aren't on disk.
+	rootDir    string
+	excludeAbs []string
+
+	// newerThan is the mtime of the -newer-than-file reference, or zero
+	newerThan time.Time
+
+	// leafDirs are the directory names shown without their contents
+	leafDirs map[string]bool
+
+	// outputFile is the absolute path of the -o file, which never lists
+	// itself
+	outputFile string
+}
+
+// defaultBinaryExts are the extensions -code-only skips unless -binary-ext
+// gives its own list
+var defaultBinaryExts = []string{
+	// Images
+	".png", ".jpg", ".jpeg", ".gif", ".bmp", ".ico", ".webp", ".tif", ".tiff", ".psd",
+	// Audio and video
+	".mp3", ".wav", ".flac", ".ogg", ".mp4", ".mov", ".avi", ".mkv", ".webm",
+	// Fonts
+	".ttf", ".otf", ".woff", ".woff2", ".eot",
+	// Archives and packages
+	".zip", ".tar", ".gz", ".tgz", ".bz2", ".xz", ".zst", ".7z", ".rar", ".jar", ".war", ".whl", ".deb", ".rpm", ".dmg", ".iso",
+	// Compiled objects and binaries
+	".o", ".a", ".so", ".dylib", ".dll", ".exe", ".lib", ".obj", ".class", ".pyc", ".pyo", ".wasm", ".bin",
+	// Documents and databases
+	".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx", ".sqlite", ".db",
+}
+
+// defaultLeafDirs are the dependency and build output directories shown
+// without their contents unless -no-default-leaves is used
+var defaultLeafDirs = []string{
+	"node_modules",
+	"bower_components",
+	"vendor",
+	"target",
+	".venv",
+	"venv",
+	"__pycache__",
+}
+
+// pythonLeafDirs are the caches and build output of Python tools, added to
+// the leaf directories by -python
+var pythonLeafDirs = []string{
+	"__pycache__",
+	".pytest_cache",
+	".mypy_cache",
+	".ruff_cache",
+	".hypothesis",
+	".ipynb_checkpoints",
+	".tox",
+	".nox",
+	".eggs",
+	"htmlcov",
+}
+
+// tempPatterns are the editor temporary and backup files skipped by -no-temp.
+// Lock files such as Emacs's .#name are hidden, and so already skipped.
+var tempPatterns = []string{
+	"*~",     // Emacs, vim and many others: backups
+	"#*#",    // Emacs: auto-save files
+	"*.swp",  // vim: swap files
+	"*.swo",  // vim: further swap files
+	"*.tmp",  // many editors: temporary saves
+	"*.bak",  // many editors: backups
+	"*.orig", // merge tools: originals of merged files
+}
+
+// newEntryFilter compiles the filters selected in opts for a tree rooted at
+// rootDir, which is empty if the tree doesn't come from the disk
+func newEntryFilter(opts *Options, rootDir string) (*entryFilter, error) {
+	types, err := parseTypeFilter(opts.TypeFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	exts := parseExts(opts.Ext)
+
+	// -code-only skips binary and asset files, by the built-in list unless
+	// -binary-ext replaces it
+	var binaryExts []string
+	if opts.CodeOnly {
+		binaryExts = defaultBinaryExts
+		if len(opts.BinaryExt) > 0 {
+			binaryExts = parseExts(opts.BinaryExt)
+		}
+	}
+
+	// -newer-than-file compares against the reference's mtime, which must
+	// exist
+	var newerThan time.Time
+	if opts.NewerThanFile != "" {
+		info, err := os.Stat(opts.NewerThanFile)
+		if err != nil {
+			return nil, fmt.Errorf("-newer-than-file: %w", err)
+		}
+		newerThan = info.ModTime()
+	}
+
+	exclude := expandAllBraces(opts.Exclude)
+	if opts.NoTemp {
+		exclude = append(exclude, tempPatterns...)
+	}
+
+	// The -o file is matched by its absolute path, wherever it sits in the
+	// tree
+	var outputFile string
+	if opts.Output != "" {
+		if outputFile, err = filepath.Abs(opts.Output); err != nil {
+			return nil, err
+		}
+	}
+
+	// Leaf directories are the defaults, unless turned off, the -python
+	// preset and -leaf-dirs, which can also take names off the list
+	leafDirs := make(map[string]bool)
+	if !opts.NoDefaultLeaves {
+		for _, name := range defaultLeafDirs {
+			leafDirs[name] = true
+		}
+	}
+	if opts.Python {
+		for _, name := range pythonLeafDirs {
+			leafDirs[name] = true
+		}
+	}
+	for _, value := range opts.LeafDirs {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if removed, ok := strings.CutPrefix(name, "!"); ok {
+				delete(leafDirs, removed)
+			} else if name != "" {
+				leafDirs[name] = true
+			}
+		}
+	}
+
+	return &entryFilter{
+		types:       types,
+		include:     expandAllBraces(opts.Include),
+		exclude:     exclude,
+		exts:        exts,
+		binaryExts:  binaryExts,
+		skipSpecial: opts.SkipSpecial,
+		rootDir:     rootDir,
+		excludeAbs:  expandAllBraces(opts.ExcludeAbs),
+		newerThan:   newerThan,
+		leafDirs:    leafDirs,
+		outputFile:  outputFile,
+	}, nil
+}
+
+// excluded reports whether an entry is dropped. Excluded directories are not
+// descended into.
+func (f *entryFilter) excluded(path string, mode fs.FileMode) bool {
+	isDir := mode.IsDir()
+
+	// Skip sockets, devices and named pipes
+	if f.skipSpecial && isSpecial(mode) {
+		return true
+	}
+
+	// Skip anything matching the exclude patterns
+	if len(f.exclude) > 0 && matchesAny(path, isDir, f.exclude) {
+		return true
+	}
+
+	// Never list the file the output is being written to
+	if f.outputFile != "" && f.rootDir != "" && filepath.Join(f.rootDir, path) == f.outputFile {
+		return true
+	}
+
+	// Skip anything whose absolute path matches an -exclude-abs pattern
+	if len(f.excludeAbs) > 0 && f.rootDir != "" && matchesAbs(filepath.Join(f.rootDir, path), isDir, f.excludeAbs) {
+		return true
+	}
+
+	// The remaining filters select files; directories are always descended
+	// into so their matching children can be found
+	if isDir {
+		return false
+	}
+
+	// Skip files that don't match the include patterns
+	if len(f.include) > 0 && !matchesAny(path, false, f.include) {
+		return true
+	}
+
+	// Skip files without one of the requested extensions
+	if len(f.exts) > 0 && !hasExt(path, f.exts) {
+		return true
+	}
+
+	// Skip binary and asset files
+	if len(f.binaryExts) > 0 && hasExt(path, f.binaryExts) {
+		return true
+	}
+
+	// Skip entries excluded by the type filter
+	return !allowedType(mode, f.types)
+}
+
+// isLeafDir reports whether a directory with this base name is shown without
+// its contents
+func (f *entryFilter) isLeafDir(name string) bool {
+	return f.leafDirs[name]
+}
+
+// hidden reports whether the entry at path is hidden because its name, or
+// the name of a directory holding it, starts with a dot. Hidden leaf
+// directories such as .venv are the exception: they are shown, without their
+// contents, like any other leaf directory.
+func (f *entryFilter) hidden(path string, isDir bool) bool {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ".") {
+			return !(i < len(parts)-1 || isDir) || !f.isLeafDir(part)
+		}
+	}
+	return false
+}
+
+// leafAncestor returns the path of the outermost leaf directory holding the
+// entry at path, or the entry itself if it is a leaf directory, or "" if
+// there is none
+func (f *entryFilter) leafAncestor(path string, isDir bool) string {
+	parts := strings.Split(path, "/")
+	if !isDir {
+		parts = parts[:len(parts)-1]
+	}
+
+	for i, part := range parts {
+		if f.isLeafDir(part) {
+			return strings.Join(parts[:i+1], "/")
+		}
+	}
+	return ""
+}
+
+// hidesDir reports whether a directory is kept only to hold its children
+// because the type filter excludes directories
+func (f *entryFilter) hidesDir(mode fs.FileMode) bool {
+	return !allowedType(mode, f.types)
+}
+
+// prune drops files that aren't newer than the -newer-than-file reference,
+// then directories left empty by the file filters. A directory named by an
+// include pattern is kept even when empty, unless extensions or mtimes are
+// filtered too.
+func (f *entryFilter) prune(root *Node) {
+	if !f.newerThan.IsZero() {
+		dropOlderFiles(root, f.newerThan)
+	}
+
+	if len(f.include) == 0 && len(f.exts) == 0 && f.newerThan.IsZero() {
+		return
+	}
+
+	pruneEmptyDirs(root, func(n *Node) bool {
+		return n.Collapsed || len(f.include) > 0 && len(f.exts) == 0 && f.newerThan.IsZero() && matchesAny(n.Path, true, f.include)
+	})
+}
+
+// dropOlderFiles removes the files, and symlinks, whose mtime isn't after t
+func dropOlderFiles(n *Node, t time.Time) {
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		if child.IsDir {
+			dropOlderFiles(child, t)
+		} else if !child.ModTime.After(t) {
+			continue
+		}
+		children = append(children, child)
+	}
+	n.Children = children
+}
+
+// parseExts normalizes extension flag values, which may be comma-separated
+// lists of extensions with or without the leading dot
+func parseExts(values []string) []string {
+	var exts []string
+	for _, value := range values {
+		for _, ext := range strings.Split(value, ",") {
+			if ext = strings.TrimSpace(ext); ext != "" {
+				exts = append(exts, "."+strings.TrimPrefix(ext, "."))
+			}
+		}
+	}
+	return exts
+}
+
+// hasExt checks if the path ends in one of the extensions, ignoring case
+func hasExt(path string, exts []string) bool {
+	ext := filepath.Ext(path)
+	for _, want := range exts {
+		if strings.EqualFold(ext, want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTypeFilterTextTree(t *testing.T) {
+	root := makeTree(t,
+		"a/deep/er/deep.txt",
+		"a/f1",
+		"b/link -> ../fx1",
+		"empty/sub/",
+		"fx1",
+	)
+
+	tests := []struct {
+		types string
+		args  []string
+		want  string
+	}{
+		{"f", nil, lines(
+			"root",
+			"├── a/deep/er/deep.txt",
+			"├── a/f1",
+			"└── fx1",
+		)},
+		{"l", nil, lines(
+			"root",
+			"└── b/link",
+		)},
+		{"fl", nil, lines(
+			"root",
+			"├── a/deep/er/deep.txt",
+			"├── a/f1",
+			"├── b/link",
+			"└── fx1",
+		)},
+		{"d", nil, lines(
+			"root",
+			"├── a",
+			"│   └── deep",
+			"│       └── er",
+			"├── b",
+			"└── empty",
+			"    └── sub",
+		)},
+		{"fd", nil, lines(
+			"root",
+			"├── a",
+			"│   ├── deep",
+			"│   │   └── er",
+			"│   │       └── deep.txt",
+			"│   └── f1",
+			"├── b",
+			"├── empty",
+			"│   └── sub",
+			"└── fx1",
+		)},
+		{"f", []string{"-plain-indent"}, lines(
+			"root",
+			"  a/deep/er/deep.txt",
+			"  a/f1",
+			"  fx1",
+		)},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-type-filter", tt.types}, tt.args...)
+		if got := render(t, append(args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", args, got, tt.want)
+		}
+	}
+}
+
+func TestIncludeAnchoring(t *testing.T) {
+	root := makeTree(t,
+		"cmd/main.go",
+		"cmd/README.md",
+		"internal/cmd/tool.go",
+		"go.mod",
+		"main.go",
+	)
+
+	tests := []struct {
+		include []string
+		want    string
+	}{
+		{[]string{"*.go"}, lines(
+			"root",
+			"├── cmd",
+			"│   └── main.go",
+			"├── internal",
+			"│   └── cmd",
+			"│       └── tool.go",
+			"└── main.go",
+		)},
+		{[]string{"/*.go"}, lines(
+			"root",
+			"└── main.go",
+		)},
+		{[]string{"/cmd/**"}, lines(
+			"root",
+			"└── cmd",
+			"    ├── README.md",
+			"    └── main.go",
+		)},
+		{[]string{"cmd"}, lines(
+			"root",
+			"├── cmd",
+			"│   ├── README.md",
+			"│   └── main.go",
+			"└── internal",
+			"    └── cmd",
+			"        └── tool.go",
+		)},
+		{[]string{"/cmd"}, lines(
+			"root",
+			"└── cmd",
+			"    ├── README.md",
+			"    └── main.go",
+		)},
+		{[]string{"/go.mod", "cmd/*.md"}, lines(
+			"root",
+			"├── cmd",
+			"│   └── README.md",
+			"└── go.mod",
+		)},
+	}
+
+	for _, tt := range tests {
+		var args []string
+		for _, pattern := range tt.include {
+			args = append(args, "-include", pattern)
+		}
+		if got := render(t, append(args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.include, got, tt.want)
+		}
+	}
+}
+
+func TestNewerThanFile(t *testing.T) {
+	root := makeTree(t, "old.txt", "same.txt", "new.txt", "src/old.go", "src/new.go", "stale/old.go", "empty/")
+	ref := filepath.Join(t.TempDir(), "ref")
+	writeFile(t, filepath.Dir(ref), "ref", "")
+
+	stamp := time.Now().Add(-time.Hour)
+	mtimes := map[string]time.Time{
+		ref:            stamp,
+		"old.txt":      stamp.Add(-time.Minute),
+		"same.txt":     stamp,
+		"new.txt":      stamp.Add(time.Minute),
+		"src/old.go":   stamp.Add(-time.Minute),
+		"src/new.go":   stamp.Add(time.Second),
+		"stale/old.go": stamp.Add(-time.Hour),
+	}
+	for path, mtime := range mtimes {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, filepath.FromSlash(path))
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only files modified strictly after the reference are kept, and
+	// directories left without any are dropped
+	want := lines(
+		"root",
+		"├── new.txt",
+		"└── src",
+		"    └── new.go",
+	)
+	if got := render(t, "-newer-than-file", ref, root); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, _, err := runDirtext(t, "-newer-than-file", filepath.Join(root, "missing"), root); err == nil {
+		t.Error("a missing reference file was accepted")
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.go", []string{"*.go"}},
+		{"*.{go,md}", []string{"*.go", "*.md"}},
+		{"{a,{b,c}}", []string{"a", "b", "c"}},
+		{"a{,b}", []string{"a", "ab"}},
+		{"{a,b}{1,2}", []string{"a1", "a2", "b1", "b2"}},
+		{"/{cmd,docs}/**", []string{"/cmd/**", "/docs/**"}},
+		{"{}", []string{"{}"}},
+		{"{a}", []string{"{a}"}},
+		{"x{a,b", []string{"x{a,b"}},
+		{"{a}{b,c}", []string{"{a}b", "{a}c"}},
+	}
+
+	for _, tt := range tests {
+		if got := expandBraces(tt.pattern); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandBraces(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestBraceExpansionFlags(t *testing.T) {
+	root := makeTree(t, "a.go", "b.md", "c.txt", "{x,y}.log", "x.log")
+	writeFile(t, root, ".gitignore", "{x,y}.log\n")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-include", "*.{go,md}"}, lines(
+			"root",
+			"├── a.go",
+			"└── b.md",
+		)},
+		{[]string{"-exclude", "{a,b}.*"}, lines(
+			"root",
+			"├── c.txt",
+			"└── x.log",
+		)},
+		// .gitignore patterns aren't expanded, so only the literal name is
+		// ignored
+		{nil, lines(
+			"root",
+			"├── a.go",
+			"├── b.md",
+			"├── c.txt",
+			"└── x.log",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	if _, _, err := runDirtext(t, "-require", "*.{rs,py}", root); err == nil {
+		t.Error("-require '*.{rs,py}' passed without a match")
+	}
+	render(t, "-require", "*.{rs,md}", root)
+}
+
+func TestNoTemp(t *testing.T) {
+	root := makeTree(t,
+		"#notes.md#",
+		"a.txt",
+		"a.txt~",
+		"dir/.b.go.swp",
+		"dir/b.go",
+		"dir/b.go.orig",
+		"dir/c.swo",
+		"draft.tmp",
+		"old.bak",
+	)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-no-temp"}, lines(
+			"root",
+			"├── a.txt",
+			"└── dir",
+			"    └── b.go",
+		)},
+		{nil, lines(
+			"root",
+			"├── #notes.md#",
+			"├── a.txt",
+			"├── a.txt~",
+			"├── dir",
+			"│   ├── b.go",
+			"│   ├── b.go.orig",
+			"│   └── c.swo",
+			"├── draft.tmp",
+			"└── old.bak",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCodeOnly(t *testing.T) {
+	root := makeTree(t, "LOGO.PNG", "archive.tar.gz", "img/a.png", "lib.so", "main.go", "x.svg")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// Extensions are compared ignoring case, and directories left
+		// without files stay
+		{[]string{"-code-only"}, lines(
+			"root",
+			"├── img",
+			"├── main.go",
+			"└── x.svg",
+		)},
+		// -binary-ext replaces the built-in list
+		{[]string{"-code-only", "-binary-ext", "png,svg", "-binary-ext", ".SO"}, lines(
+			"root",
+			"├── archive.tar.gz",
+			"├── img",
+			"└── main.go",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	if _, _, err := runDirtext(t, "-binary-ext", "png", root); err == nil {
+		t.Error("-binary-ext without -code-only was accepted")
+	}
+}
+
+func TestLeafDirs(t *testing.T) {
+	root := makeTree(t,
+		".git/config",
+		".venv/lib/site.py",
+		"app/dist/app.js",
+		"app/node_modules/left-pad/index.js",
+		"app/src/index.js",
+		"target/debug/app",
+		"vendor/lib/lib.go",
+	)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// Hidden leaf directories are shown too, other hidden entries aren't
+		{nil, lines(
+			"root",
+			"├── .venv [...]",
+			"├── app",
+			"│   ├── dist",
+			"│   │   └── app.js",
+			"│   ├── node_modules [...]",
+			"│   └── src",
+			"│       └── index.js",
+			"├── target [...]",
+			"└── vendor [...]",
+		)},
+		{[]string{"-leaf-dirs", "dist,src", "-leaf-dirs", "!vendor"}, lines(
+			"root",
+			"├── .venv [...]",
+			"├── app",
+			"│   ├── dist [...]",
+			"│   ├── node_modules [...]",
+			"│   └── src [...]",
+			"├── target [...]",
+			"└── vendor",
+			"    └── lib",
+			"        └── lib.go",
+		)},
+		{[]string{"-no-default-leaves", "-leaf-dirs", "debug"}, lines(
+			"root",
+			"├── app",
+			"│   ├── dist",
+			"│   │   └── app.js",
+			"│   ├── node_modules",
+			"│   │   └── left-pad",
+			"│   │       └── index.js",
+			"│   └── src",
+			"│       └── index.js",
+			"├── target",
+			"│   └── debug [...]",
+			"└── vendor",
+			"    └── lib",
+			"        └── lib.go",
+		)},
+		// Leaf directories stay when filters leave them empty
+		{[]string{"-ext", "go"}, lines(
+			"root",
+			"├── .venv [...]",
+			"├── app",
+			"│   └── node_modules [...]",
+			"├── target [...]",
+			"└── vendor [...]",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestLeafDirsNotRead(t *testing.T) {
+	root := makeTree(t, "node_modules/pkg/index.js", "src/main.go")
+
+	// An unreadable leaf directory isn't an error, since it's never listed
+	lockDir(t, filepath.Join(root, "node_modules", "pkg"))
+
+	want := lines(
+		"root",
+		"├── node_modules [...]",
+		"└── src",
+		"    └── main.go (sha256:e3b0c44298fc)",
+	)
+	stdout, stderr, err := runDirtext(t, "-hash", root)
+	if err != nil || stderr != "" {
+		t.Fatalf("%v\n%s", err, stderr)
+	}
+	if stdout != want {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout, want)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	root := makeTree(t, "README.md", "cmd/tool/main.go", "docs/", "build.log")
+	writeFile(t, root, ".gitignore", "*.log\n")
+
+	tests := []struct {
+		args    []string
+		missing []string
+	}{
+		{[]string{"-require", "README.md", "-require", "/cmd/*/main.go"}, nil},
+		// Directories count too
+		{[]string{"-require", "docs", "-require", "cmd/tool"}, nil},
+		{[]string{"-require", "{README,LICENSE}*"}, nil},
+		{[]string{"-require", "LICENSE", "-require", "README.md", "-require", "/tool"}, []string{"LICENSE", "/tool"}},
+		// Ignored and filtered entries don't count
+		{[]string{"-require", "build.log"}, []string{"build.log"}},
+		{[]string{"-require", "README.md", "-ext", "go"}, []string{"README.md"}},
+	}
+
+	for _, tt := range tests {
+		stdout, stderr, err := runDirtext(t, append(tt.args, root)...)
+		if len(tt.missing) == 0 {
+			if err != nil || stderr != "" {
+				t.Errorf("%v: %v\n%s", tt.args, err, stderr)
+			}
+			continue
+		}
+
+		if want := plural(len(tt.missing), "required path") + " missing"; err == nil || err.Error() != want {
+			t.Errorf("%v: error %v, want %q", tt.args, err, want)
+		}
+		var want string
+		for _, pattern := range tt.missing {
+			want += "Missing required path: " + pattern + "\n"
+		}
+		if stderr != want {
+			t.Errorf("%v: stderr %q, want %q", tt.args, stderr, want)
+		}
+
+		// The tree is still written
+		if !strings.HasPrefix(stdout, "root\n") {
+			t.Errorf("%v: no tree:\n%s", tt.args, stdout)
+		}
+	}
+}
+
+func TestPythonLeafDirs(t *testing.T) {
+	root := makeTree(t,
+		".mypy_cache/3.12/x.json",
+		".pytest_cache/v/cache",
+		".tox/py312/bin/python",
+		"htmlcov/index.html",
+		"pkg/__pycache__/mod.cpython-312.pyc",
+		"pkg/mod.py",
+	)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// Only __pycache__ is a default leaf, and hidden directories aren't
+		// shown
+		{nil, lines(
+			"root",
+			"├── htmlcov",
+			"│   └── index.html",
+			"└── pkg",
+			"    ├── __pycache__ [...]",
+			"    └── mod.py",
+		)},
+		{[]string{"-python"}, lines(
+			"root",
+			"├── .mypy_cache [...]",
+			"├── .pytest_cache [...]",
+			"├── .tox [...]",
+			"├── htmlcov [...]",
+			"└── pkg",
+			"    ├── __pycache__ [...]",
+			"    └── mod.py",
+		)},
+		{[]string{"-python", "-leaf-dirs", "!htmlcov,!.tox"}, lines(
+			"root",
+			"├── .mypy_cache [...]",
+			"├── .pytest_cache [...]",
+			"├── htmlcov",
+			"│   └── index.html",
+			"└── pkg",
+			"    ├── __pycache__ [...]",
+			"    └── mod.py",
+		)},
+		// -python keeps its names without the defaults, __pycache__ among them
+		{[]string{"-python", "-no-default-leaves"}, lines(
+			"root",
+			"├── .mypy_cache [...]",
+			"├── .pytest_cache [...]",
+			"├── .tox [...]",
+			"├── htmlcov [...]",
+			"└── pkg",
+			"    ├── __pycache__ [...]",
+			"    └── mod.py",
+		)},
+		{[]string{"-python", "-flat"}, lines(
+			".mypy_cache",
+			".pytest_cache",
+			".tox",
+			"htmlcov",
+			"pkg",
+			"pkg/__pycache__",
+			"pkg/mod.py",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestExcludeAbs(t *testing.T) {
+	root := makeTree(t, "a.txt", "b.txt", "cache/x", "cache/sub/y", "src/cache/z", "src/main.go")
+	abs := filepath.ToSlash(root)
+
+	tests := []struct {
+		patterns []string
+		want     string
+	}{
+		{[]string{abs + "/a.txt"}, lines("b.txt", "cache", "cache/sub", "cache/sub/y", "cache/x", "src", "src/cache", "src/cache/z", "src/main.go")},
+		// A matching directory takes its subtree with it
+		{[]string{abs + "/cache"}, lines("a.txt", "b.txt", "src", "src/cache", "src/cache/z", "src/main.go")},
+		{[]string{abs + "/*/cache"}, lines("a.txt", "b.txt", "cache", "cache/sub", "cache/sub/y", "cache/x", "src", "src/main.go")},
+		{[]string{"/**/cache"}, lines("a.txt", "b.txt", "src", "src/main.go")},
+		{[]string{abs + "/*.txt", abs + "/src"}, lines("cache", "cache/sub", "cache/sub/y", "cache/x")},
+		// Patterns are matched against the whole path, so one outside the
+		// root, or naming the root's entries without its location, matches
+		// nothing
+		{[]string{"/elsewhere/a.txt", "/a.txt", "/cache"}, lines("a.txt", "b.txt", "cache", "cache/sub", "cache/sub/y", "cache/x", "src", "src/cache", "src/cache/z", "src/main.go")},
+	}
+
+	for _, tt := range tests {
+		var args []string
+		for _, p := range tt.patterns {
+			args = append(args, "-exclude-abs", p)
+		}
+		if got := render(t, append(append(args, "-flat"), root)...); got != tt.want {
+			t.Errorf("%q:\ngot:\n%s\nwant:\n%s", tt.patterns, got, tt.want)
+		}
+	}
+
+	// Relative patterns are rejected
+	for _, pattern := range []string{"cache", "*/cache", "./a.txt"} {
+		if _, _, err := runDirtext(t, "-exclude-abs", pattern, root); err == nil {
+			t.Errorf("-exclude-abs %q was accepted", pattern)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitTrackedTree builds the tree of files git tracks under rootDir. The
+// tracked paths go through the same filters as a walk of the disk, except
+// .gitignore, which doesn't apply to tracked files.
+func gitTrackedTree(rootDir string, opts *Options) (*Node, error) {
+	entries, err := gitTrackedEntries(rootDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return treeFromEntries(filepath.Base(rootDir), rootDir, entries, opts)
+}
+
+// gitTrackedEntries lists the files git tracks under rootDir, with their
+// modes and sizes taken from the working tree
+func gitTrackedEntries(rootDir string, opts *Options) ([]pathEntry, error) {
+	// git prints paths relative to the directory it runs in, always with '/'
+	out, err := gitOutput(rootDir, "ls-files", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []pathEntry
+	for _, relPath := range strings.Split(out, "\x00") {
+		if relPath == "" {
+			continue
+		}
+
+		entry := pathEntry{Path: relPath}
+		path := filepath.Join(rootDir, filepath.FromSlash(relPath))
+
+		// Tracked files missing from the working tree, as in a sparse
+		// checkout, are still listed unless -existing-only is set
+		info, err := os.Lstat(path)
+		if err != nil {
+			if opts.ExistingOnly {
+				continue
+			}
+			entry.Missing = true
+		} else {
+			entry.Mode = info.Mode()
+			entry.Size = info.Size()
+			entry.ModTime = info.ModTime()
+
+			// Never reveal where a symlink leading out of the root points,
+			// as in a walk of the disk
+			isLink := info.Mode()&os.ModeSymlink != 0
+			entry.OutsideRoot = opts.NoEscapeRoot && isLink && escapesRoot(path, rootDir)
+
+			if opts.LinkTargets && isLink && !entry.OutsideRoot {
+				entry.LinkTarget, err = linkTarget(path, rootDir, opts.RelativeLinks)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// gitOutput runs a git command in dir and returns its stdout
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+
+	return string(out), nil
+}
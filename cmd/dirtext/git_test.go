@@ -0,0 +1,195 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initRepo makes root a git repository with everything in it committed
+// except the paths in untracked, skipping the test if git isn't available
+func initRepo(t *testing.T, root string, untracked ...string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't available")
+	}
+
+	runGit(t, root, "", "init", "-q")
+	runGit(t, root, "", "add", "-A")
+	for _, path := range untracked {
+		runGit(t, root, "", "--literal-pathspecs", "rm", "-q", "--cached", "--", path)
+	}
+	runGit(t, root, "", "commit", "-q", "-m", "fixture")
+}
+
+// runGit runs git in dir with stdin as its input, isolated from the user's
+// configuration, and returns its output
+func runGit(t *testing.T, dir, stdin string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_GLOBAL="+os.DevNull,
+		"GIT_CONFIG_SYSTEM="+os.DevNull,
+		"GIT_AUTHOR_NAME=Ada",
+		"GIT_AUTHOR_EMAIL=ada@example.com",
+		"GIT_COMMITTER_NAME=Ada",
+		"GIT_COMMITTER_EMAIL=ada@example.com",
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		var stderr []byte
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			stderr = exitErr.Stderr
+		}
+		t.Fatalf("git %v: %v\n%s", args, err, stderr)
+	}
+	return string(out)
+}
+
+func TestGitTrackedFilters(t *testing.T) {
+	root := makeTree(t,
+		"cmd/main.go",
+		"cmd/main_test.go",
+		"cmd/README.md",
+		"go.mod",
+		"scratch.go",
+		"vendor/lib/lib.go",
+	)
+	initRepo(t, root, "scratch.go")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-ext", ".go"}, lines(
+			"root",
+			"├── cmd",
+			"│   ├── main.go",
+			"│   └── main_test.go",
+			"└── vendor [...]",
+		)},
+		{[]string{"-ext", "go", "-no-default-leaves", "-exclude", "*_test.go"}, lines(
+			"root",
+			"├── cmd",
+			"│   └── main.go",
+			"└── vendor",
+			"    └── lib",
+			"        └── lib.go",
+		)},
+		{[]string{"-include", "/cmd/**", "-type-filter", "f"}, lines(
+			"root",
+			"├── cmd/README.md",
+			"├── cmd/main.go",
+			"└── cmd/main_test.go",
+		)},
+		{[]string{"-include", "*.md", "-flat"}, lines(
+			"cmd",
+			"cmd/README.md",
+		)},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-git-tracked"}, tt.args...)
+		if got := render(t, append(args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestGitTrackedNoEscapeRoot(t *testing.T) {
+	root := makeTree(t,
+		"a.txt",
+		"in -> a.txt",
+		"out -> ../outside",
+	)
+	initRepo(t, root)
+
+	// The same as a walk of the disk
+	want := lines(
+		"root",
+		"├── a.txt",
+		"├── in -> a.txt",
+		"└── out (outside root)",
+	)
+	for _, args := range [][]string{
+		{"-git-tracked", "-link-targets", "-no-escape-root"},
+		{"-link-targets", "-no-escape-root"},
+	} {
+		if got := render(t, append(args, root)...); got != want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", args, got, want)
+		}
+	}
+}
+
+func TestGitTrackedMissingFiles(t *testing.T) {
+	root := makeTree(t, "a.txt", "b.txt")
+	writeFile(t, root, "a.txt", "a\n")
+	initRepo(t, root)
+
+	// As in a sparse checkout
+	if err := os.Remove(filepath.Join(root, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := lines(
+		"root",
+		"├── a.txt (sha256:87428fc52280)",
+		"└── b.txt (not checked out)",
+	)
+	if got := render(t, "-git-tracked", "-hash", root); got != want {
+		t.Errorf("-hash:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, args := range [][]string{
+		{"-hardlinks"},
+		{"-preview", "1"},
+		{"-dupes"},
+	} {
+		render(t, append(append([]string{"-git-tracked"}, args...), root)...)
+	}
+}
+
+func TestBlameAuthor(t *testing.T) {
+	root := makeTree(t, "README.md", "main.go", "sub/sp ace.go", "sub/util.go", "scratch.go")
+	initRepo(t, root, "scratch.go")
+
+	// A later commit by someone else takes over main.go and sub/util.go
+	writeFile(t, root, "main.go", "package main\n")
+	writeFile(t, root, "sub/util.go", "package sub\n")
+	runGit(t, root, "", "commit", "-q", "-a", "-m", "edit", "--author", "Linus <linus@example.com>")
+
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		// Untracked files get no note
+		{root, lines(
+			"root",
+			"├── README.md (by Ada)",
+			"├── main.go (by Linus)",
+			"├── scratch.go",
+			"└── sub",
+			"    ├── sp ace.go (by Ada)",
+			"    └── util.go (by Linus)",
+		)},
+		// Paths are read relative to a scan root below the top of the
+		// repository
+		{filepath.Join(root, "sub"), lines(
+			"sub",
+			"├── sp ace.go (by Ada)",
+			"└── util.go (by Linus)",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, "-blame-author", tt.dir); got != tt.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", tt.dir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// gzipChunkSize is roughly how much uncompressed output -gzip writes between
+// flushes
+const gzipChunkSize = 64 << 10
+
+// gzipWriter compresses what is written to it as it arrives. The compressor
+// is flushed at the first newline after every gzipChunkSize bytes, so a
+// stream cut short decompresses up to a record boundary.
+type gzipWriter struct {
+	zw   *gzip.Writer
+	file *os.File
+
+	// unflushed counts the bytes written since the last flush
+	unflushed int
+	closed    bool
+}
+
+// createGzip starts a compressed stream to the file name, or to stdout if
+// name is empty
+func createGzip(name string, stdout io.Writer) (*gzipWriter, error) {
+	if name == "" {
+		return &gzipWriter{zw: gzip.NewWriter(stdout)}, nil
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &gzipWriter{zw: gzip.NewWriter(f), file: f}, nil
+}
+
+func (g *gzipWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// Write up to the newline that ends the current chunk, or all of p
+		end := len(p)
+		from := max(gzipChunkSize-g.unflushed, 0)
+		if from < len(p) {
+			if i := bytes.IndexByte(p[from:], '\n'); i >= 0 {
+				end = from + i + 1
+			}
+		}
+
+		n, err := g.zw.Write(p[:end])
+		written += n
+		g.unflushed += n
+		if err != nil {
+			return written, err
+		}
+
+		if g.unflushed >= gzipChunkSize && p[end-1] == '\n' {
+			if err := g.zw.Flush(); err != nil {
+				return written, err
+			}
+			g.unflushed = 0
+		}
+		p = p[end:]
+	}
+	return written, nil
+}
+
+// Close ends the stream and closes its file. Closing it again does nothing.
+func (g *gzipWriter) Close() error {
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+
+	err := g.zw.Close()
+	if g.file != nil {
+		if closeErr := g.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// trimFinalNewline passes writes through to w, holding back a trailing
+// newline until more output follows, so the stream doesn't end with one
+type trimFinalNewline struct {
+	w       io.Writer
+	pending bool
+}
+
+func (t *trimFinalNewline) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if t.pending {
+		if _, err := t.w.Write([]byte("\n")); err != nil {
+			return 0, err
+		}
+	}
+
+	body := bytes.TrimSuffix(p, []byte("\n"))
+	t.pending = len(body) < len(p)
+	if _, err := t.w.Write(body); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
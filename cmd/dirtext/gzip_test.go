@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gunzip decompresses data, failing the test if it isn't a complete stream
+func gunzip(t *testing.T, data []byte) string {
+	t.Helper()
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestGzipRoundTrip(t *testing.T) {
+	a := makeTree(t, "a.txt", "dir/b.txt")
+	b := makeTree(t, "c.txt")
+
+	for _, args := range [][]string{
+		nil,
+		{"-flat"},
+		{"-format", "ndjson"},
+		{"-no-trailing-newline"},
+		{"-flat", "-no-trailing-newline"},
+	} {
+		args = append(args, a, b)
+		plain := render(t, args...)
+
+		// To stdout and to a file
+		stdout := render(t, append([]string{"-gzip"}, args...)...)
+		if got := gunzip(t, []byte(stdout)); got != plain {
+			t.Errorf("%v to stdout:\ngot:\n%q\nwant:\n%q", args, got, plain)
+		}
+
+		name := filepath.Join(t.TempDir(), "tree.gz")
+		render(t, append([]string{"-gzip", "-o", name}, args...)...)
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := gunzip(t, data); got != plain {
+			t.Errorf("%v to -o:\ngot:\n%q\nwant:\n%q", args, got, plain)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-gzip", "-fence"},
+		{"-gzip", "-format", "gh-details"},
+		{"-gzip", "-pager"},
+	} {
+		if _, _, err := runDirtext(t, append(args, a)...); err == nil {
+			t.Errorf("%v was accepted", args)
+		}
+	}
+}
+
+func TestGzipWriterFlushesAtLines(t *testing.T) {
+	var input strings.Builder
+	for i := 0; input.Len() < 3*gzipChunkSize; i++ {
+		fmt.Fprintf(&input, `{"path":"dir/file%06d.go"}`+"\n", i)
+	}
+
+	// Written in small pieces that split lines, as a renderer would
+	var compressed bytes.Buffer
+	gz := &gzipWriter{zw: gzip.NewWriter(&compressed)}
+	data := []byte(input.String())
+	for len(data) > 0 {
+		n := min(1000, len(data))
+		if _, err := gz.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+
+	// Cut before the end, the stream still decompresses at least up to the
+	// last flush
+	cut := compressed.Bytes()[:compressed.Len()*3/4]
+	zr, err := gzip.NewReader(bytes.NewReader(cut))
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial, _ := io.ReadAll(zr)
+	if len(partial) < 2*gzipChunkSize || !strings.HasPrefix(input.String(), string(partial)) {
+		t.Errorf("read back %d bytes of %d", len(partial), input.Len())
+	}
+
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := gunzip(t, compressed.Bytes()); got != input.String() {
+		t.Errorf("round trip lost data: %d bytes, want %d", len(got), input.Len())
+	}
+}
+
+func TestTrimFinalNewline(t *testing.T) {
+	var out bytes.Buffer
+	w := &trimFinalNewline{w: &out}
+	for _, s := range []string{"a\n", "", "b\nc\n", "\n", "d\n"} {
+		w.Write([]byte(s))
+	}
+	if got, want := out.String(), "a\nb\nc\n\nd"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// inodeKey identifies a file across its hard links
+type inodeKey struct {
+	dev, ino uint64
+}
+
+// annotateHardlinks notes which files in the tree share an inode. Each set
+// of links is numbered in display order, and the note gives the inode's link
+// count, which may include links outside the tree. Platforms without inode
+// numbers get a warning and no notes.
+func annotateHardlinks(rootDir string, root *Node, stderr io.Writer) error {
+	if !hasInodes {
+		fmt.Fprintln(stderr, "Warning: -hardlinks isn't supported on this platform")
+		return nil
+	}
+
+	groups := make(map[inodeKey]int)
+	return walkNodesErr(root, func(n *Node) error {
+		if !n.Mode.IsRegular() || n.Missing {
+			return nil
+		}
+
+		info, err := os.Lstat(filepath.Join(rootDir, filepath.FromSlash(n.Path)))
+		if err != nil {
+			return err
+		}
+
+		key, links, ok := fileInode(info)
+		if !ok || links < 2 {
+			return nil
+		}
+
+		group, seen := groups[key]
+		if !seen {
+			group = len(groups) + 1
+			groups[key] = group
+		}
+		n.Notes = append(n.Notes, fmt.Sprintf("hardlink group %d, %d links", group, links))
+		return nil
+	})
+}
+
+// walkNodesErr calls fn for n and each of its descendants in display order,
+// stopping at the first error
+func walkNodesErr(n *Node, fn func(*Node) error) error {
+	if err := fn(n); err != nil {
+		return err
+	}
+	for _, child := range n.Children {
+		if err := walkNodesErr(child, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// hasInodes reports whether fileInode can identify hard links
+const hasInodes = false
+
+// fileInode can't identify hard links on this platform
+func fileInode(info fs.FileInfo) (inodeKey, uint64, bool) {
+	return inodeKey{}, 0, false
+}
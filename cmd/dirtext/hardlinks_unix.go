@@ -0,0 +1,20 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// hasInodes reports whether fileInode can identify hard links
+const hasInodes = true
+
+// fileInode returns the device and inode of a file and its link count
+func fileInode(info fs.FileInfo) (inodeKey, uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return inodeKey{}, 0, false
+	}
+	return inodeKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}, uint64(st.Nlink), true
+}
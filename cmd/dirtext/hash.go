@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// hashFiles computes the SHA-256 of each path (relative to rootDir) using one
+// worker per CPU. The result maps each path to its hex digest.
+func hashFiles(rootDir string, paths []string) (map[string]string, error) {
+	type result struct {
+		path string
+		sum  string
+		err  error
+	}
+
+	jobs := make(chan string)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				sum, err := hashFile(filepath.Join(rootDir, filepath.FromSlash(path)))
+				results <- result{path: path, sum: sum, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		for _, path := range paths {
+			jobs <- path
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	// Drain every result even after an error so the workers can exit
+	sums := make(map[string]string, len(paths))
+	var firstErr error
+	for r := range results {
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		sums[r.path] = r.sum
+	}
+
+	return sums, firstErr
+}
+
+// hashTree sets the Hash of every regular file in the tree
+func hashTree(rootDir string, root *Node) error {
+	sums, err := hashFiles(rootDir, hashedPaths(root))
+	if err != nil {
+		return err
+	}
+
+	walkNodes(root, func(n *Node) {
+		n.Hash = sums[n.Path]
+	})
+	return nil
+}
+
+// hashedPaths returns the paths of the files hashTree reads: every regular
+// file in the tree that exists on disk
+func hashedPaths(root *Node) []string {
+	var paths []string
+	walkNodes(root, func(n *Node) {
+		if n.Mode.IsRegular() && !n.IsDir && !n.Missing {
+			paths = append(paths, n.Path)
+		}
+	})
+	return paths
+}
+
+// hashFile returns the hex SHA-256 of the file's contents
+func hashFile(path string) (string, error) {
+	file, err := openReadOnly(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
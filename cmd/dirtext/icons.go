@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// defaultIcons maps extensions to Nerd Font glyphs for -icons. The
+// "directory" and "file" keys hold the glyphs of directories and of files
+// with no icon of their own.
+var defaultIcons = map[string]string{
+	"directory": "\uf07b", // nf-fa-folder
+	"file":      "\uf15b", // nf-fa-file
+
+	".c":    "\ue61e", // nf-custom-c
+	".cpp":  "\ue61d", // nf-custom-cpp
+	".css":  "\ue749", // nf-dev-css3
+	".go":   "\ue627", // nf-seti-go
+	".h":    "\uf0fd", // nf-fa-h_square
+	".html": "\ue736", // nf-dev-html5
+	".java": "\ue738", // nf-dev-java
+	".js":   "\ue74e", // nf-dev-javascript
+	".json": "\ue60b", // nf-seti-json
+	".lock": "\uf023", // nf-fa-lock
+	".md":   "\uf48a", // nf-oct-markdown
+	".mod":  "\ue627", // nf-seti-go
+	".py":   "\ue606", // nf-seti-python
+	".rb":   "\ue739", // nf-dev-ruby
+	".rs":   "\ue7a8", // nf-dev-rust
+	".sh":   "\uf489", // nf-oct-terminal
+	".sum":  "\ue627", // nf-seti-go
+	".toml": "\ue615", // nf-seti-config
+	".ts":   "\ue628", // nf-seti-typescript
+	".txt":  "\uf15c", // nf-fa-file_text
+	".yaml": "\ue615", // nf-seti-config
+	".yml":  "\ue615", // nf-seti-config
+}
+
+// loadIcons returns the glyphs for -icons: the defaults, with those in the
+// JSON object in iconsFile, if given, added or replacing them
+func loadIcons(iconsFile string) (map[string]string, error) {
+	icons := make(map[string]string, len(defaultIcons))
+	for key, glyph := range defaultIcons {
+		icons[key] = glyph
+	}
+	if iconsFile == "" {
+		return icons, nil
+	}
+
+	file, err := openReadOnly(iconsFile)
+	if err != nil {
+		return nil, fmt.Errorf("-icons-file: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("-icons-file: %w", err)
+	}
+
+	var overrides map[string]string
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("-icons-file %s: %w", iconsFile, err)
+	}
+	for key, glyph := range overrides {
+		icons[strings.ToLower(key)] = glyph
+	}
+	return icons, nil
+}
+
+// addIcons puts the glyph of each entry's type before its name
+func addIcons(root *Node, icons map[string]string) {
+	walkNodes(root, func(n *Node) {
+		if n == root {
+			return
+		}
+
+		glyph, ok := icons[strings.ToLower(path.Ext(n.Name))]
+		switch {
+		case n.IsDir:
+			glyph = icons["directory"]
+		case !ok:
+			glyph = icons["file"]
+		}
+		if glyph != "" {
+			n.Name = glyph + " " + n.Name
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIcons(t *testing.T) {
+	root := makeTree(t, "a.go", "d/x.txt", "plain")
+	iconsFile := filepath.Join(t.TempDir(), "icons.json")
+	if err := os.WriteFile(iconsFile, []byte(`{".GO": "G", ".txt": "", "file": "F"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	drawn := lines(
+		"root",
+		"├── \ue627 a.go",
+		"├── \uf07b d",
+		"│   └── \uf15c x.txt",
+		"└── \uf15b plain",
+	)
+	custom := lines(
+		"root",
+		"├── G a.go",
+		"├── \uf07b d",
+		"│   └── x.txt",
+		"└── F plain",
+	)
+	plain := lines(
+		"root",
+		"├── a.go",
+		"├── d",
+		"│   └── x.txt",
+		"└── plain",
+	)
+
+	// Without a terminal only -deterministic draws them, -o files included
+	out := filepath.Join(t.TempDir(), "tree.txt")
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-icons"}, plain},
+		{[]string{"-icons", "-deterministic"}, drawn},
+		{[]string{"-icons", "-deterministic", "-icons-file", iconsFile}, custom},
+	} {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+
+		render(t, append(tt.args, "-o", out, root)...)
+		if got, err := os.ReadFile(out); err != nil || string(got) != tt.want {
+			t.Errorf("%v -o:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	// The icons file is read like every other file
+	var opened []string
+	openedFile = func(path string) { opened = append(opened, path) }
+	defer func() { openedFile = nil }()
+	render(t, "-icons", "-deterministic", "-icons-file", iconsFile, root)
+	if !slices.Contains(opened, iconsFile) {
+		t.Errorf("-icons-file wasn't opened through openReadOnly: %q", opened)
+	}
+}
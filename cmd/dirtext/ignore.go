@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gitignoreFiles caches the patterns of every .gitignore file loaded during a
+// run, keyed by absolute path, so roots that share ignore files only parse
+// them once. An entry is reused only while the file's mtime and size are
+// unchanged, in case it is edited between roots.
+type gitignoreFiles struct {
+	// mu guards files, as roots may be scanned concurrently
+	mu    sync.Mutex
+	files map[string]cachedGitignore
+}
+
+// cachedGitignore is one loaded .gitignore file
+type cachedGitignore struct {
+	modTime  time.Time
+	size     int64
+	patterns []string
+}
+
+// newGitignoreFiles creates an empty cache
+func newGitignoreFiles() *gitignoreFiles {
+	return &gitignoreFiles{files: make(map[string]cachedGitignore)}
+}
+
+// load returns the patterns of the .gitignore file in dir
+func (g *gitignoreFiles) load(dir string) ([]string, error) {
+	gitignorePath := filepath.Join(dir, ".gitignore")
+
+	info, err := os.Stat(gitignorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	cached, ok := g.files[gitignorePath]
+	g.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.patterns, nil
+	}
+
+	patterns, err := loadGitignore(gitignorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	g.files[gitignorePath] = cachedGitignore{modTime: info.ModTime(), size: info.Size(), patterns: patterns}
+	g.mu.Unlock()
+	return patterns, nil
+}
+
+// loadGitignore loads patterns from .gitignore file
+func loadGitignore(gitignorePath string) ([]string, error) {
+	file, err := openReadOnly(gitignorePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Clean up the pattern
+		pattern := line
+
+		// A leading slash is kept: it anchors the pattern to the directory
+		// containing the .gitignore (see match)
+
+		// Handle directory-only patterns (ending with /)
+		pattern = strings.TrimSuffix(pattern, "/")
+
+		patterns = append(patterns, pattern)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return patterns, nil
+}
+
+// shouldIgnore checks if a path should be ignored based on gitignore patterns.
+// matched reports whether any pattern applied to the path at all, so callers
+// can fall back to the patterns of a parent directory.
+func shouldIgnore(path string, isDir bool, patterns []string) (ignored, matched bool) {
+	for _, pattern := range patterns {
+		// Handle negated patterns
+		if strings.HasPrefix(pattern, "!") {
+			negatedPattern := strings.TrimPrefix(pattern, "!")
+			if match(path, negatedPattern, isDir) {
+				return false, true
+			}
+			continue
+		}
+
+		// Check if the pattern matches
+		if match(path, pattern, isDir) {
+			return true, true
+		}
+	}
+
+	return false, false
+}
+
+// match checks if a path matches a gitignore pattern
+func match(path string, pattern string, isDir bool) bool {
+	// Convert gitignore glob pattern to Go's filepath.Match pattern
+	// This is a simplified implementation
+
+	// A leading slash anchors the pattern to the root (the directory holding
+	// the .gitignore, or the scan root for CLI patterns), so it must match
+	// the whole path rather than any trailing part of it
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	// Handle directory wildcards (**)
+	if strings.Contains(pattern, "**") {
+		// Replace ** with a special marker
+		pattern = strings.Replace(pattern, "**", "[[RECURSIVE]]", -1)
+
+		// Split both the pattern and path into components
+		patternParts := strings.Split(pattern, "/")
+		pathParts := strings.Split(path, "/")
+
+		return recursiveMatch(pathParts, patternParts, 0, 0)
+	}
+
+	// Simple matching using filepath.Match
+	matched, _ := filepath.Match(pattern, path)
+	if matched || anchored {
+		return matched
+	}
+
+	// Check for partial path match
+	// e.g., if pattern is "build", it should match both "build" and "path/to/build"
+	return strings.HasSuffix(path, pattern) ||
+		strings.Contains(path, pattern+"/")
+}
+
+// recursiveMatch handles ** pattern matching
+func recursiveMatch(path, pattern []string, pathIdx, patternIdx int) bool {
+	// End conditions
+	if patternIdx >= len(pattern) {
+		return pathIdx >= len(path)
+	}
+
+	if pathIdx >= len(path) {
+		// Check if remaining patterns are all **
+		for i := patternIdx; i < len(pattern); i++ {
+			if pattern[i] != "[[RECURSIVE]]" {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Handle ** pattern
+	if pattern[patternIdx] == "[[RECURSIVE]]" {
+		// Try to match at current position or skip this path component
+		return recursiveMatch(path, pattern, pathIdx+1, patternIdx) ||
+			recursiveMatch(path, pattern, pathIdx, patternIdx+1) ||
+			recursiveMatch(path, pattern, pathIdx+1, patternIdx+1)
+	}
+
+	// Regular pattern matching
+	match, _ := filepath.Match(pattern[patternIdx], path[pathIdx])
+	if match {
+		return recursiveMatch(path, pattern, pathIdx+1, patternIdx+1)
+	}
+
+	return false
+}
+
+// ignoreLevel holds the patterns of one .gitignore file
+type ignoreLevel struct {
+	// dir is the directory containing the .gitignore, relative to the scan
+	// root ("" for the root itself)
+	dir      string
+	patterns []string
+}
+
+// ignoreMatcher decides whether the entries of one directory are ignored. It
+// holds every .gitignore that applies to the directory, outermost first.
+type ignoreMatcher struct {
+	levels []ignoreLevel
+}
+
+// ignored checks if a path relative to the scan root is ignored. Patterns
+// from deeper .gitignore files take precedence over those of their parents.
+func (m *ignoreMatcher) ignored(path string, isDir bool) bool {
+	for i := len(m.levels) - 1; i >= 0; i-- {
+		level := m.levels[i]
+
+		// Patterns are relative to the directory of their .gitignore
+		levelPath := path
+		if level.dir != "" {
+			levelPath = strings.TrimPrefix(path, level.dir+"/")
+		}
+
+		if ignored, matched := shouldIgnore(levelPath, isDir, level.patterns); matched {
+			return ignored
+		}
+	}
+
+	return false
+}
+
+// ignoreCache builds the ignoreMatcher for each directory once and reuses it
+// for all of that directory's direct children, so the stack of nested
+// .gitignore files isn't reassembled for every path
+type ignoreCache struct {
+	rootDir  string
+	files    *gitignoreFiles
+	stderr   io.Writer
+	matchers map[string]*ignoreMatcher
+}
+
+// newIgnoreCache creates a cache seeded with the scan root's patterns
+func newIgnoreCache(rootDir string, rootPatterns []string, files *gitignoreFiles, stderr io.Writer) *ignoreCache {
+	root := &ignoreMatcher{}
+	if len(rootPatterns) > 0 {
+		root.levels = []ignoreLevel{{dir: "", patterns: rootPatterns}}
+	}
+
+	return &ignoreCache{
+		rootDir:  rootDir,
+		files:    files,
+		stderr:   stderr,
+		matchers: map[string]*ignoreMatcher{"": root},
+	}
+}
+
+// matcher returns the matcher for a directory relative to the scan root,
+// loading the directory's own .gitignore the first time it is seen
+func (c *ignoreCache) matcher(dir string) *ignoreMatcher {
+	if m, ok := c.matchers[dir]; ok {
+		return m
+	}
+
+	parent := c.matcher(parentDir(dir))
+
+	patterns, err := c.files.load(filepath.Join(c.rootDir, filepath.FromSlash(dir)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(c.stderr, "Warning: couldn't load %s/.gitignore: %v\n", dir, err)
+	}
+
+	// Directories without their own patterns share their parent's matcher
+	m := parent
+	if len(patterns) > 0 {
+		levels := make([]ignoreLevel, len(parent.levels), len(parent.levels)+1)
+		copy(levels, parent.levels)
+		m = &ignoreMatcher{levels: append(levels, ignoreLevel{dir: dir, patterns: patterns})}
+	}
+
+	c.matchers[dir] = m
+	return m
+}
+
+// parentDir returns the parent of a '/'-separated relative path, or "" for
+// top-level entries
+func parentDir(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return ""
+	}
+	return path[:i]
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "debug.log", false, true},
+		{"*.log", "debug.log.txt", false, false},
+
+		// A leading slash anchors the pattern
+		{"/*.log", "debug.log", false, true},
+		{"/*.log", "logs/debug.log", false, false},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+
+		// Plain names match at any depth
+		{"build", "src/build", true, true},
+
+		// Patterns with a slash match from the root
+		{"logs/*.log", "logs/debug.log", false, true},
+		{"logs/*.log", "old/logs/debug.log", false, false},
+
+		// ** spans any number of directories
+		{"**/cache", "a/b/cache", true, true},
+		{"docs/**", "docs/a/b.md", false, true},
+		{"docs/**", "src/docs.md", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := match(tt.path, tt.pattern, tt.isDir); got != tt.want {
+			t.Errorf("match(%q, %q) = %v, want %v", tt.path, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+// ignoredPath is one entry to check against the ignore patterns
+type ignoredPath struct {
+	path  string
+	isDir bool
+}
+
+// nestedIgnoreTree creates a tree fanout directories wide and depth deep
+// with a .gitignore and a few files in every directory, returning its root
+// and every path in it
+func nestedIgnoreTree(b *testing.B, fanout, depth int) (string, []ignoredPath) {
+	b.Helper()
+
+	root := makeTree(b)
+	var paths []ignoredPath
+
+	var fill func(dir string, level int)
+	fill = func(dir string, level int) {
+		writeFile(b, root, path.Join(dir, ".gitignore"), fmt.Sprintf("*.tmp\nbuild%d/\n/local\n!keep.tmp\n**/cache\n", level))
+		for _, name := range []string{"a.go", "b.tmp", "keep.tmp", "local", "README.md"} {
+			p := path.Join(dir, name)
+			writeFile(b, root, p, "")
+			paths = append(paths, ignoredPath{p, false})
+		}
+
+		if level == depth {
+			return
+		}
+		for i := 0; i < fanout; i++ {
+			sub := path.Join(dir, fmt.Sprintf("dir%d", i))
+			paths = append(paths, ignoredPath{sub, true})
+			fill(sub, level+1)
+		}
+	}
+	fill("", 0)
+
+	return root, paths
+}
+
+// BenchmarkIgnoreMatcher compares reusing each directory's matcher for all
+// its children against assembling the stack of .gitignore files again for
+// every path. Both share the parsed files, so only the per-path work
+// differs.
+func BenchmarkIgnoreMatcher(b *testing.B) {
+	root, paths := nestedIgnoreTree(b, 3, 4)
+	files := newGitignoreFiles()
+	rootPatterns, err := files.load(root)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ignores := newIgnoreCache(root, rootPatterns, files, io.Discard)
+			for _, p := range paths {
+				ignores.matcher(parentDir(p.path)).ignored(p.path, p.isDir)
+			}
+		}
+	})
+
+	b.Run("per-path", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, p := range paths {
+				ignores := newIgnoreCache(root, rootPatterns, files, io.Discard)
+				ignores.matcher(parentDir(p.path)).ignored(p.path, p.isDir)
+			}
+		}
+	})
+}
+
+func TestGitignoreFilesReload(t *testing.T) {
+	root := makeTree(t)
+	writeFile(t, root, ".gitignore", "*.log\n")
+	files := newGitignoreFiles()
+
+	load := func() []string {
+		t.Helper()
+		patterns, err := files.load(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return patterns
+	}
+
+	first := load()
+	if want := []string{"*.log"}; !reflect.DeepEqual(first, want) {
+		t.Fatalf("got %q, want %q", first, want)
+	}
+
+	// Unchanged files come from the cache, even if the copy on disk could
+	// no longer be parsed
+	key := filepath.Join(root, ".gitignore")
+	cached := files.files[key]
+	cached.patterns = []string{"cached"}
+	files.files[key] = cached
+	if got := load(); !reflect.DeepEqual(got, []string{"cached"}) {
+		t.Errorf("unchanged file: got %q, want the cached patterns", got)
+	}
+
+	// A file edited between roots is parsed again, whether its size or its
+	// mtime changed
+	writeFile(t, root, ".gitignore", "*.tmp\nbuild/\n")
+	if got, want := load(), []string{"*.tmp", "build"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after resizing: got %q, want %q", got, want)
+	}
+
+	writeFile(t, root, ".gitignore", "*.out\nbuild/\n")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, ".gitignore"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := load(), []string{"*.out", "build"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after touching: got %q, want %q", got, want)
+	}
+}
+
+// BenchmarkSharedGitignoreFiles renders a repository and each of its
+// subdirectories as separate roots, comparing one Renderer, which parses
+// each .gitignore once, against a Renderer per root, which parses the
+// subdirectories' files again for every root that contains them
+func BenchmarkSharedGitignoreFiles(b *testing.B) {
+	root := makeTree(b)
+	roots := []string{root}
+	var patterns strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&patterns, "# generated object %d\n/obj%d.o\n", i, i)
+	}
+	for i := 0; i < 20; i++ {
+		dir := fmt.Sprintf("pkg%d", i)
+		writeFile(b, root, dir+"/.gitignore", patterns.String())
+		writeFile(b, root, dir+"/main.go", "")
+		roots = append(roots, filepath.Join(root, dir))
+	}
+
+	opts, err := parseFlags([]string{"-no-summary"}, io.Discard)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("shared", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			renderer := newRenderer(opts)
+			for _, dir := range roots {
+				if _, err := renderer.Render(io.Discard, dir, io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("per-root", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, dir := range roots {
+				if _, err := newRenderer(opts).Render(io.Discard, dir, io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
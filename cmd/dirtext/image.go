@@ -0,0 +1,221 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Whiteout markers used by Docker and OCI image layers. A file named
+// ".wh.<name>" deletes <name> from the layers below, and a directory
+// containing ".wh..wh..opq" hides everything the lower layers put in it.
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// loadImageTree reads a `docker save` or OCI image tarball and returns the
+// merged filesystem of its first layers, or of every layer if layers is 0
+func loadImageTree(archivePath string, layers int, opts *Options) (*Node, error) {
+	layerPaths, err := imageLayers(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if layers < 0 || layers > len(layerPaths) {
+		return nil, fmt.Errorf("image has %d layers, can't select layer %d", len(layerPaths), layers)
+	}
+	if layers > 0 {
+		layerPaths = layerPaths[:layers]
+	}
+
+	// Apply each layer on top of the ones below it
+	merged := make(map[string]pathEntry)
+	for _, layerPath := range layerPaths {
+		err := withArchiveMember(archivePath, layerPath, func(r io.Reader) error {
+			return applyLayer(merged, r)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %s: %w", layerPath, err)
+		}
+	}
+
+	entries := make([]pathEntry, 0, len(merged))
+	for _, e := range merged {
+		entries = append(entries, e)
+	}
+
+	return treeFromEntries(filepath.Base(archivePath), "", entries, opts)
+}
+
+// imageLayers returns the archive paths of an image's layers, bottom first. It
+// understands both the `docker save` manifest.json and the OCI index.json.
+func imageLayers(archivePath string) ([]string, error) {
+	var dockerManifest []struct {
+		Layers []string
+	}
+	err := withArchiveMember(archivePath, "manifest.json", func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&dockerManifest)
+	})
+	if err == nil {
+		if len(dockerManifest) == 0 {
+			return nil, errors.New("manifest.json lists no images")
+		}
+		return dockerManifest[0].Layers, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// Fall back to the OCI image layout: index.json points at a manifest
+	// blob, which lists the layer blobs
+	var index struct {
+		Manifests []struct {
+			Digest string
+		}
+	}
+	err = withArchiveMember(archivePath, "index.json", func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&index)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a docker or OCI image archive: %w", archivePath, err)
+	}
+	if len(index.Manifests) == 0 {
+		return nil, errors.New("index.json lists no manifests")
+	}
+
+	var manifest struct {
+		Layers []struct {
+			Digest string
+		}
+	}
+	err = withArchiveMember(archivePath, blobPath(index.Manifests[0].Digest), func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&manifest)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading image manifest: %w", err)
+	}
+
+	var layerPaths []string
+	for _, layer := range manifest.Layers {
+		layerPaths = append(layerPaths, blobPath(layer.Digest))
+	}
+	return layerPaths, nil
+}
+
+// blobPath converts a digest such as "sha256:abc" to its OCI layout path
+func blobPath(digest string) string {
+	algorithm, hex, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", algorithm, hex)
+}
+
+// withArchiveMember finds the named member of the tar archive and calls fn
+// with its contents. It returns fs.ErrNotExist if there is no such member.
+func withArchiveMember(archivePath, name string, fn func(io.Reader) error) error {
+	file, err := openReadOnly(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	tr := tar.NewReader(file)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+		}
+		if err != nil {
+			return err
+		}
+
+		if cleanArchivePath(hdr.Name) == name {
+			return fn(tr)
+		}
+	}
+}
+
+// applyLayer reads one layer tarball, which may be gzip-compressed, and
+// applies its whiteouts and entries to merged
+func applyLayer(merged map[string]pathEntry, r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	// Whiteouts only hide entries from lower layers, so collect this layer's
+	// entries and apply them after the deletions
+	var added []pathEntry
+	var removed, opaque []string
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := cleanArchivePath(hdr.Name)
+		if name == "" {
+			continue
+		}
+
+		dir, base := parentDir(name), path.Base(name)
+		switch {
+		case base == whiteoutOpaque:
+			opaque = append(opaque, dir)
+		case strings.HasPrefix(base, whiteoutPrefix):
+			removed = append(removed, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+		default:
+			added = append(added, pathEntry{
+				Path:       name,
+				Mode:       hdr.FileInfo().Mode(),
+				Size:       hdr.Size,
+				ModTime:    hdr.ModTime,
+				LinkTarget: hdr.Linkname,
+			})
+		}
+	}
+
+	for p := range merged {
+		for _, gone := range removed {
+			if p == gone || strings.HasPrefix(p, gone+"/") {
+				delete(merged, p)
+			}
+		}
+		for _, dir := range opaque {
+			if dir == "" || strings.HasPrefix(p, dir+"/") {
+				delete(merged, p)
+			}
+		}
+	}
+
+	for _, e := range added {
+		merged[e.Path] = e
+	}
+
+	return nil
+}
+
+// cleanArchivePath normalizes a tar member name to a relative '/' path
+func cleanArchivePath(name string) string {
+	name = path.Clean("/" + name)
+	return strings.TrimPrefix(name, "/")
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// jsonNode is the JSON representation of a Node
+type jsonNode struct {
+	Name       string      `json:"name"`
+	RelPath    string      `json:"relPath,omitempty"`
+	IsDir      bool        `json:"isDir"`
+	LinkTarget string      `json:"linkTarget,omitempty"`
+	Children   []*jsonNode `json:"children,omitempty"`
+}
+
+// renderJSON writes the tree as nested JSON objects. With paths set each node
+// also carries its '/'-separated path relative to the scan root ("." for the
+// root itself).
+func renderJSON(w io.Writer, root *Node, paths bool) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(toJSONNode(root, paths))
+}
+
+// toJSONNode converts n and its visible descendants
+func toJSONNode(n *Node, paths bool) *jsonNode {
+	jn := &jsonNode{
+		Name:       n.Name,
+		IsDir:      n.IsDir,
+		LinkTarget: n.LinkTarget,
+	}
+
+	if paths {
+		jn.RelPath = n.Path
+		if jn.RelPath == "" {
+			jn.RelPath = "."
+		}
+	}
+
+	for _, child := range visibleChildren(n) {
+		jn.Children = append(jn.Children, toJSONNode(child, paths))
+	}
+
+	return jn
+}
+
+// renderPathsJSON writes the relative paths of the visible entries as a JSON
+// array of strings, in the same order as -flat
+func renderPathsJSON(w io.Writer, root *Node) error {
+	paths := []string{}
+	walkNodes(root, func(n *Node) {
+		if n != root && !n.Filtered {
+			paths = append(paths, n.Path)
+		}
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(paths)
+}
+
+// jsonEntry is one element of -json-stream output, and one line of -format
+// ndjson
+type jsonEntry struct {
+	Path       string `json:"path"`
+	IsDir      bool   `json:"isDir"`
+	Size       int64  `json:"size"`
+	LinkTarget string `json:"linkTarget,omitempty"`
+}
+
+// renderNDJSON writes every visible entry as a JSON object on a line of its
+// own, in display order
+func renderNDJSON(w io.Writer, root *Node) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	var err error
+	walkNodes(root, func(n *Node) {
+		if n == root || n.Filtered || err != nil {
+			return
+		}
+		err = enc.Encode(jsonEntry{Path: n.Path, IsDir: n.IsDir, Size: n.Size, LinkTarget: n.LinkTarget})
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// renderJSONStream writes every visible entry as one flat JSON array,
+// encoding a single element at a time rather than building the nested
+// document first. If limit isn't 0 only the first limit entries are written.
+func renderJSONStream(w io.Writer, root *Node, limit int) error {
+	bw := bufio.NewWriter(w)
+
+	// Each element is encoded into buf, which is reused, so only one entry is
+	// held at a time
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	var err error
+	count := 0
+	bw.WriteString("[")
+	walkNodes(root, func(n *Node) {
+		if n == root || n.Filtered || err != nil || (limit > 0 && count == limit) {
+			return
+		}
+
+		buf.Reset()
+		if err = enc.Encode(jsonEntry{Path: n.Path, IsDir: n.IsDir, Size: n.Size, LinkTarget: n.LinkTarget}); err != nil {
+			return
+		}
+
+		if count > 0 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n  ")
+		bw.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+		count++
+	})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		bw.WriteString("\n")
+	}
+	bw.WriteString("]\n")
+
+	return bw.Flush()
+}
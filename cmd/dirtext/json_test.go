@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONPaths(t *testing.T) {
+	root := makeTree(t, "a.txt", "cmd/dirtext/main.go", "cmd/dirtext/sub dir/x.go", "docs/")
+
+	var doc jsonNode
+	if err := json.Unmarshal([]byte(render(t, "-format", "json", "-json-paths", root)), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every node's path, by its name and the names of its ancestors
+	got := map[string]string{}
+	var collect func(n *jsonNode, names string)
+	collect = func(n *jsonNode, names string) {
+		got[names+n.Name] = n.RelPath
+		for _, child := range n.Children {
+			collect(child, names+n.Name+">")
+		}
+	}
+	collect(&doc, "")
+
+	want := map[string]string{
+		"root":                          ".",
+		"root>a.txt":                    "a.txt",
+		"root>cmd":                      "cmd",
+		"root>cmd>dirtext":              "cmd/dirtext",
+		"root>cmd>dirtext>main.go":      "cmd/dirtext/main.go",
+		"root>cmd>dirtext>sub dir":      "cmd/dirtext/sub dir",
+		"root>cmd>dirtext>sub dir>x.go": "cmd/dirtext/sub dir/x.go",
+		"root>docs":                     "docs",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Without -json-paths there are none
+	var lean jsonNode
+	if plain := render(t, "-format", "json", root); json.Unmarshal([]byte(plain), &lean) != nil || lean.RelPath != "" {
+		t.Errorf("relPath without -json-paths:\n%s", plain)
+	}
+}
+
+// flatTree returns a root holding n files
+func flatTree(n int) *Node {
+	root := &Node{Name: "root", IsDir: true}
+	for i := range n {
+		name := fmt.Sprintf("file%05d.go", i)
+		root.Children = append(root.Children, &Node{Name: name, Path: name, Size: int64(i)})
+	}
+	return root
+}
+
+func TestJSONStream(t *testing.T) {
+	root := makeTree(t, "a.txt", "cmd/dirtext/main.go", "docs/")
+
+	// One entry per line, for the same paths as -flat
+	out := render(t, "-format", "json", "-json-stream", root)
+	var entries []jsonEntry
+	if err := json.Unmarshal([]byte(out), &entries); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.Path)
+	}
+	if flat := render(t, "-flat", root); strings.Join(paths, "\n")+"\n" != flat {
+		t.Errorf("paths %q, want the -flat paths\n%s", paths, flat)
+	}
+	if strings.Count(out, "\n") != len(entries)+2 {
+		t.Errorf("not one entry per line:\n%s", out)
+	}
+
+	// An empty tree is still an array
+	if got := render(t, "-format", "json", "-json-stream", "-quiet", makeTree(t)); got != "[]\n" {
+		t.Errorf("empty tree: %q", got)
+	}
+}
+
+func TestJSONStreamAllocs(t *testing.T) {
+	// Each entry is encoded on its own, so the allocations per entry don't
+	// grow with the tree as they would when the whole document is encoded
+	perEntry := func(n int) float64 {
+		root := flatTree(n)
+		return testing.AllocsPerRun(5, func() {
+			if err := renderJSONStream(io.Discard, root, 0); err != nil {
+				t.Fatal(err)
+			}
+		}) / float64(n)
+	}
+
+	small, large := perEntry(100), perEntry(10000)
+	if large > small*1.5+1 {
+		t.Errorf("%.2f allocations per entry for 10000 entries, %.2f for 100", large, small)
+	}
+}
+
+func TestPathsJSONMatchesFlat(t *testing.T) {
+	root := makeTree(t, "B.txt", "a/z.go", "a/b/c.go", "ln -> a", `we"ird\name`, "sp ace/x")
+	writeFile(t, root, "big", strings.Repeat("x", 100))
+
+	for _, args := range [][]string{
+		nil,
+		{"-dirs-first"},
+		{"-tree-sort"},
+		{"-sort", "size"},
+		{"-type-filter", "f"},
+		{"-type-filter", "dl"},
+		{"-ext", "go"},
+	} {
+		var paths []string
+		out := render(t, append(append([]string{"-format", "paths-json"}, args...), root)...)
+		if err := json.Unmarshal([]byte(out), &paths); err != nil {
+			t.Fatalf("%v: %v\n%s", args, err, out)
+		}
+
+		flat := render(t, append(append([]string{"-flat"}, args...), root)...)
+		if got := strings.Join(paths, "\n") + "\n"; got != flat {
+			t.Errorf("%v:\npaths-json:\n%s\n-flat:\n%s", args, got, flat)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// legendEntry explains one marker that can appear in a text tree
+type legendEntry struct {
+	marker  string
+	meaning string
+}
+
+// legendEntries returns the markers found in a text tree, in the order they
+// appear on a line. Only what was rendered counts: a -max-per-dir that left
+// nothing out adds no "... (N more)" entry.
+func legendEntries(root *Node, opts *Options) []legendEntry {
+	used := make(map[string]bool)
+	walkNodes(root, func(n *Node) {
+		if n.LinkTarget != "" {
+			used["name -> target"] = true
+		}
+		if n.Hash != "" {
+			used["(sha256:…)"] = true
+		}
+		for _, note := range n.Notes {
+			used[noteMarker(note)] = true
+		}
+		if n.Collapsed {
+			used["[...]"] = true
+		}
+		if len(n.Preview) > 0 {
+			used["indented lines"] = true
+		}
+		if n.More > 0 {
+			used["... (N more)"] = true
+		}
+	})
+	used["glyph name"] = opts.Icons && len(root.Children) > 0
+
+	collapsed := "leaf directory shown without its contents"
+	if opts.NodeBudget > 0 {
+		collapsed = "directory shown without its contents, a leaf one or past -node-budget"
+	}
+
+	// Name the options that can have left the entries out
+	var limits []string
+	if opts.MaxPerDir > 0 {
+		limits = append(limits, "-max-per-dir")
+	}
+	if opts.SizeBudget > 0 {
+		limits = append(limits, "-size-budget")
+	}
+	if opts.NodeBudget > 0 {
+		limits = append(limits, "-node-budget")
+	}
+	more := "entries left out"
+	switch n := len(limits); {
+	case n == 1:
+		more += " by " + limits[0]
+	case n > 1:
+		more += " by " + strings.Join(limits[:n-1], ", ") + " or " + limits[n-1]
+	}
+
+	var entries []legendEntry
+	for _, e := range []legendEntry{
+		{"glyph name", "Nerd Font icon for the entry's type"},
+		{"name -> target", "symbolic link and where it points"},
+		{"(sha256:…)", "first 12 hex digits of the file's SHA-256"},
+		{"(outside root)", "symbolic link leading out of the scan root, target hidden"},
+		{"(not checked out)", "tracked file missing from the working tree"},
+		{"(by name)", "author of the last commit to the file"},
+		{"(hardlink group N, M links)", "file sharing an inode with the others of group N"},
+		{"(depth N)", "levels beneath the scan root"},
+		{"(added)", "only in this tree"},
+		{"(removed)", "only in the -compare directory"},
+		{"(changed)", "in both, with different contents"},
+		{"[...]", collapsed},
+		{"indented lines", "first lines of the file above"},
+		{"... (N more)", more},
+	} {
+		if used[e.marker] {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// noteMarker returns the legend marker for a note, with the parts that vary
+// from entry to entry replaced by placeholders
+func noteMarker(note string) string {
+	switch {
+	case strings.HasPrefix(note, "by "):
+		return "(by name)"
+	case strings.HasPrefix(note, "hardlink group "):
+		return "(hardlink group N, M links)"
+	case strings.HasPrefix(note, "depth "):
+		return "(depth N)"
+	}
+	return "(" + note + ")"
+}
+
+// writeLegend writes the entries under a "Legend:" heading, with the
+// meanings aligned in a column
+func writeLegend(w io.Writer, entries []legendEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	width := 0
+	for _, e := range entries {
+		width = max(width, displayWidth(e.marker))
+	}
+
+	fmt.Fprintln(w, "Legend:")
+	for _, e := range entries {
+		pad := strings.Repeat(" ", width-displayWidth(e.marker))
+		fmt.Fprintf(w, "  %s%s  %s\n", e.marker, pad, e.meaning)
+	}
+}
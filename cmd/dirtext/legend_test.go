@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLegend(t *testing.T) {
+	root := makeTree(t, "a", "b", "c", "dir/x", "link -> a", "node_modules/pkg/index.js")
+	writeFile(t, root, "b", "bb")
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		// Nothing marked, so no legend
+		{[]string{"-no-default-leaves", "-max-per-dir", "50"}, nil},
+		{nil, []string{
+			"  [...]  leaf directory shown without its contents",
+		}},
+		{[]string{"-link-targets", "-show-depth"}, []string{
+			"  name -> target  symbolic link and where it points",
+			"  (depth N)       levels beneath the scan root",
+			"  [...]           leaf directory shown without its contents",
+		}},
+		// -max-per-dir drops the link, so its marker isn't explained
+		{[]string{"-link-targets", "-show-depth", "-max-per-dir", "2"}, []string{
+			"  (depth N)     levels beneath the scan root",
+			"  ... (N more)  entries left out by -max-per-dir",
+		}},
+		// The -size-budget cut is explained too, and [...] is left out once
+		// the budget has dropped node_modules
+		{[]string{"-size-budget", "1", "-max-per-dir", "50"}, []string{
+			"  ... (N more)  entries left out by -max-per-dir or -size-budget",
+		}},
+		{[]string{"-node-budget", "3", "-no-default-leaves"}, []string{
+			"  ... (N more)  entries left out by -node-budget",
+		}},
+		{[]string{"-node-budget", "5"}, []string{
+			"  [...]         directory shown without its contents, a leaf one or past -node-budget",
+			"  ... (N more)  entries left out by -node-budget",
+		}},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-legend"}, tt.args...)
+		out := render(t, append(args, root)...)
+
+		var got []string
+		if _, legend, ok := strings.Cut(out, "Legend:\n"); ok {
+			got = strings.Split(strings.TrimSuffix(legend, "\n"), "\n")
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s\ntree:\n%s", tt.args, strings.Join(got, "\n"), strings.Join(tt.want, "\n"), out)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// linkTarget reads the target of the symlink at path. If relativize is set and
+// an absolute target falls within rootDir, it is rewritten relative to the
+// link's own directory, e.g. "../shared/lib" instead of "/repo/shared/lib".
+func linkTarget(path, rootDir string, relativize bool) (string, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !relativize || !filepath.IsAbs(target) || !withinRoot(rootDir, target) {
+		return target, nil
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(path), target)
+	if err != nil {
+		return target, nil
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// withinRoot checks if the absolute path lies inside rootDir (or is rootDir)
+func withinRoot(rootDir, path string) bool {
+	rel, err := filepath.Rel(rootDir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// escapesRoot checks if the symlink at path resolves to a location outside
+// rootDir. Dangling links are judged by their target path alone.
+func escapesRoot(path, rootDir string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return false
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		resolved = target
+	}
+
+	// Compare against the resolved root too, in case the root itself is
+	// reached through a symlink
+	if realRoot, err := filepath.EvalSymlinks(rootDir); err == nil {
+		rootDir = realRoot
+	}
+
+	return !withinRoot(rootDir, resolved)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNoEscapeRoot(t *testing.T) {
+	root := makeTree(t,
+		"a.txt",
+		"in -> a.txt",
+		"sub/up -> ../a.txt",
+		"out -> ../outside/secret",
+		"dangling -> ../../nowhere",
+	)
+	writeFile(t, filepath.Dir(root), "outside/secret", "")
+	if err := os.Symlink(filepath.Join(filepath.Dir(root), "outside", "secret"), filepath.Join(root, "abs-out")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "a.txt"), filepath.Join(root, "abs-in")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-no-escape-root"}, lines(
+			"root",
+			"├── a.txt",
+			"├── abs-in",
+			"├── abs-out (outside root)",
+			"├── dangling (outside root)",
+			"├── in",
+			"├── out (outside root)",
+			"└── sub",
+			"    └── up",
+		)},
+		{[]string{"-no-escape-root", "-link-targets"}, lines(
+			"root",
+			"├── a.txt",
+			"├── abs-in -> "+filepath.Join(root, "a.txt"),
+			"├── abs-out (outside root)",
+			"├── dangling (outside root)",
+			"├── in -> a.txt",
+			"├── out (outside root)",
+			"└── sub",
+			"    └── up -> ../a.txt",
+		)},
+		{[]string{"-link-targets"}, lines(
+			"root",
+			"├── a.txt",
+			"├── abs-in -> "+filepath.Join(root, "a.txt"),
+			"├── abs-out -> "+filepath.Join(filepath.Dir(root), "outside", "secret"),
+			"├── dangling -> ../../nowhere",
+			"├── in -> a.txt",
+			"├── out -> ../outside/secret",
+			"└── sub",
+			"    └── up -> ../a.txt",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+}
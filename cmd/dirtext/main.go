@@ -1,217 +1,664 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
-	"io/fs"
+	"io"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 func main() {
-	// Get current directory
-	rootDir, err := os.Getwd()
+	opts, err := parseFlags(os.Args[1:], os.Stderr)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
+		os.Exit(2)
+	}
+
+	if err := run(opts, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+}
 
-	// Load gitignore patterns
-	ignorePatterns, err := loadGitignore(rootDir)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: couldn't load .gitignore: %v\n", err)
+// run scans each root directory (the current directory if none are given)
+// and writes their trees to stdout
+func run(opts *Options, stdout, stderr io.Writer) error {
+	// Show the resolved options for debugging
+	if opts.DumpOptions {
+		if err := opts.dump(stderr); err != nil {
+			return err
+		}
 	}
 
-	// Print the root directory name
-	fmt.Println(filepath.Base(rootDir))
+	// Create a tree on disk instead of scanning one
+	if opts.Materialize != "" {
+		targetDir := "."
+		if len(opts.Roots) > 0 {
+			targetDir = opts.Roots[0]
+		}
+		return materialize(opts.Materialize, targetDir, opts, stderr)
+	}
 
-	// Walk the directory tree
-	err = filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
+	// Like gzip(1), don't fill a terminal with compressed bytes
+	if opts.Gzip && opts.Output == "" && isTerminal(stdout) {
+		return errors.New("-gzip won't write compressed output to a terminal; use -o or a pipe")
+	}
+
+	// Render a shallow clone of a remote repository, removing it afterwards
+	if opts.Fetch != "" {
+		cloneDir, cleanup, err := fetchRepo(opts.Fetch)
 		if err != nil {
 			return err
 		}
+		defer cleanup()
+
+		withClone := *opts
+		withClone.Roots = []string{cloneDir}
+		opts = &withClone
+	}
+
+	roots, err := resolveRoots(opts)
+	if err != nil {
+		return err
+	}
+
+	// -icons, like -boxed, only decorates trees drawn on a terminal, or
+	// everywhere with -deterministic, whose output never depends on where it
+	// goes
+	if opts.Icons && !opts.Deterministic && (opts.Output != "" || !isTerminal(stdout)) {
+		withoutIcons := *opts
+		withoutIcons.Icons = false
+		opts = &withoutIcons
+	}
+
+	// .gitignore files are shared between roots in the same repository, so
+	// one renderer loads them once for the whole run
+	renderer := newRenderer(opts)
+
+	// -boxed frames trees drawn on a terminal; -deterministic output never
+	// depends on where it goes, so it is always framed, -o files included
+	boxed := opts.Boxed && (opts.Deterministic || opts.Output == "" && isTerminal(stdout))
+
+	// Render into a buffer so the finished output can be post-processed.
+	// -gzip output, which has no post-processing but the final newline,
+	// is compressed root by root instead.
+	var out bytes.Buffer
+	var sink io.Writer = &out
+	var gz *gzipWriter
+	if opts.Gzip {
+		if gz, err = createGzip(opts.Output, stdout); err != nil {
+			return err
+		}
+		defer gz.Close()
 
-		// Skip the root directory itself
-		if path == rootDir {
-			return nil
+		sink = gz
+		if opts.NoTrailingNewline {
+			sink = &trimFinalNewline{w: gz}
 		}
+	}
 
-		// Get relative path
-		relPath, err := filepath.Rel(rootDir, path)
+	var scanErrors []scanError
+	missing, collisions, suspicious := 0, 0, 0
+	for i, rootDir := range roots {
+		// Separate the trees of multiple roots with a blank line, except
+		// between -slog records, which form one stream of JSON lines
+		if i > 0 && !opts.Slog {
+			if _, err := sink.Write([]byte("\n")); err != nil {
+				return err
+			}
+		}
+
+		var tree bytes.Buffer
+		report, err := renderer.Render(&tree, rootDir, stderr)
 		if err != nil {
 			return err
 		}
 
-		// Skip hidden files and directories (starting with .)
-		if isHidden(relPath) {
-			if d.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
+		rendered := tree.Bytes()
+		if boxed {
+			rendered = drawBox(rendered)
+		}
+		if _, err := sink.Write(rendered); err != nil {
+			return err
 		}
 
-		// Skip files/directories that match gitignore patterns
-		if shouldIgnore(relPath, d.IsDir(), ignorePatterns) {
-			if d.IsDir() {
-				return filepath.SkipDir
+		// Tell the errors of different roots apart
+		for _, e := range report.errors {
+			if len(roots) > 1 {
+				e.Root = rootDir
 			}
-			return nil
+			scanErrors = append(scanErrors, e)
 		}
+		missing += len(report.missing)
+		collisions += report.collisions
+		suspicious += report.suspicious
+	}
 
-		// Calculate the depth to determine indentation
-		depth := strings.Count(relPath, string(os.PathSeparator))
-
-		// Print the tree branch and the file/directory name
-		fmt.Printf("%s%s%s\n",
-			strings.Repeat("│   ", depth),
-			"├── ",
-			filepath.Base(path))
+	// Write the errors skipped during the scan
+	if opts.ErrorsJSON != "" {
+		if err := writeScanErrors(opts.ErrorsJSON, scanErrors); err != nil {
+			return err
+		}
+	}
 
-		return nil
-	})
+	output := out.Bytes()
+	if opts.Fence {
+		output = wrapInFence(output, opts.FenceLang)
+	}
+	if opts.Format == "gh-details" {
+		output = wrapInDetails(output, opts.DetailsSummary)
+	}
+	if opts.NoTrailingNewline {
+		output = bytes.TrimSuffix(output, []byte("\n"))
+	}
 
+	// Write the output to a file if requested, to the pager when it goes to
+	// a terminal, or to stdout, or finish the -gzip stream
+	if gz != nil {
+		err = gz.Close()
+	} else if opts.Output != "" {
+		err = os.WriteFile(opts.Output, output, 0o644)
+	} else if opts.Pager && isTerminal(stdout) {
+		err = page(output, stdout, stderr)
+	} else {
+		_, err = stdout.Write(output)
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error walking directory: %v\n", err)
-		os.Exit(1)
+		return err
+	}
+
+	// The tree is still written when required paths are missing or -strict
+	// checks fail, so the failure can be seen in context
+	if missing > 0 {
+		return fmt.Errorf("%s missing", plural(missing, "required path"))
+	}
+	if opts.Strict && collisions > 0 {
+		return fmt.Errorf("%s found", plural(collisions, "case collision"))
+	}
+	if opts.Strict && suspicious > 0 {
+		return fmt.Errorf("%s found", plural(suspicious, "suspicious mtime"))
 	}
+	return nil
 }
 
-// isHidden checks if a file or directory is hidden (starts with .)
-func isHidden(path string) bool {
-	// Split the path into components
-	parts := strings.Split(path, string(os.PathSeparator))
+// resolveRoots returns the absolute root directories to scan: the positional
+// arguments, or the current directory if there are none. With -glob-roots
+// each argument is expanded as a glob, and with -repo-root or -go-module-root
+// each is replaced by the root of its git repository or Go module.
+func resolveRoots(opts *Options) ([]string, error) {
+	// An image archive is its own root
+	if opts.OCI != "" {
+		return []string{""}, nil
+	}
+
+	args := opts.Roots
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	// Expand glob arguments into the directories they match
+	if opts.GlobRoots {
+		var expanded []string
+		for _, arg := range args {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("bad root glob %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no directories match %s", arg)
+			}
+			expanded = append(expanded, matches...)
+		}
+		args = expanded
+	}
+
+	var roots []string
+	for _, arg := range args {
+		rootDir, err := filepath.Abs(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := os.Stat(rootDir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", arg)
+		}
+
+		// Use the enclosing repository root if requested
+		if opts.RepoRoot {
+			rootDir, err = findRepoRoot(rootDir)
+			if err != nil {
+				return nil, err
+			}
+		}
 
-	// Check if any component starts with a dot
-	for _, part := range parts {
-		if strings.HasPrefix(part, ".") {
-			return true
+		// Or the directory of the enclosing Go module
+		if opts.GoModuleRoot {
+			rootDir, err = findGoModuleRoot(rootDir)
+			if err != nil {
+				return nil, err
+			}
 		}
+
+		roots = append(roots, rootDir)
 	}
 
-	return false
+	return roots, nil
 }
 
-// loadGitignore loads patterns from .gitignore file
-func loadGitignore(rootDir string) ([]string, error) {
-	gitignorePath := filepath.Join(rootDir, ".gitignore")
+// renderRoot scans one root and renders its tree into out
+func renderRoot(out *bytes.Buffer, rootDir string, opts *Options, stderr io.Writer, ignoreFiles *gitignoreFiles, report *scanReport) error {
+	// Scan the directory tree, or read it from an image archive or git
+	var root *Node
+	var err error
+	if opts.OCI != "" {
+		root, err = loadImageTree(opts.OCI, opts.OCILayers, opts)
+	} else if opts.GitTracked {
+		root, err = gitTrackedTree(rootDir, opts)
+	} else {
+		var cache *scanCache
+		if opts.Cache {
+			cache = openScanCache(opts.CacheDir, rootDir)
+		}
+
+		root, err = buildTree(rootDir, opts, stderr, ignoreFiles, report, cache)
 
-	file, err := os.Open(gitignorePath)
+		// A cache that can't be saved only costs speed on the next run
+		if err == nil && cache != nil {
+			if err := cache.save(); err != nil {
+				fmt.Fprintf(stderr, "Warning: couldn't save scan cache: %v\n", err)
+			}
+			report.cacheHits = cache.hits
+		}
+	}
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	// Label a Go module's tree with its module path
+	if opts.GoModuleRoot {
+		if root.Name, err = goModulePath(rootDir); err != nil {
+			return err
+		}
+	}
+
+	// Warn about names that would clash on a case-insensitive filesystem
+	if opts.CheckCaseCollisions {
+		for _, c := range findCaseCollisions(root) {
+			dir := c.Dir
+			if dir == "" {
+				dir = "."
+			}
+			fmt.Fprintf(stderr, "Warning: names in %s differ only in case: %s\n", dir, strings.Join(c.Names, ", "))
+			report.collisions++
+		}
+	}
+
+	// Warn about modification times that look like placeholders or skew
+	if opts.SuspiciousMtime {
+		for _, w := range suspiciousMtimes(root, time.Now()) {
+			fmt.Fprintf(stderr, "Warning: suspicious mtime on %s: %s (%s)\n", w.path, w.mtime.UTC().Format(time.RFC3339), w.reason)
+			report.suspicious++
+		}
+	}
+
+	// Check that the entries the tree must contain are there
+	if len(opts.Require) > 0 {
+		report.missing = missingRequirements(root, opts.Require)
+		for _, pattern := range report.missing {
+			fmt.Fprintf(stderr, "Missing required path: %s\n", pattern)
+		}
+	}
+
+	// Note who last committed each file
+	if opts.BlameAuthor {
+		if err := annotateAuthors(rootDir, root); err != nil {
+			return err
+		}
+	}
+
+	// Note files that are hard links to the same inode
+	if opts.Hardlinks {
+		if err := annotateHardlinks(rootDir, root, stderr); err != nil {
+			return err
+		}
 	}
-	defer file.Close()
 
-	var patterns []string
-	scanner := bufio.NewScanner(file)
+	// With -list-reads the files the content options would open are only
+	// recorded
+	reads := newFileReads()
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	// Hash file contents
+	if opts.Hash && opts.ListReads {
+		reads.add(rootDir, hashedPaths(root))
+	} else if opts.Hash {
+		if err := hashTree(rootDir, root); err != nil {
+			return err
+		}
+	}
 
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	// Merge in the tree of the directory to compare against
+	if opts.Compare != "" {
+		otherDir, err := filepath.Abs(opts.Compare)
+		if err != nil {
+			return err
 		}
 
-		// Clean up the pattern
-		pattern := line
+		other, err := buildTree(otherDir, opts, stderr, ignoreFiles, &scanReport{}, nil)
+		if err != nil {
+			return err
+		}
+		if opts.Hash && opts.ListReads {
+			reads.add(otherDir, hashedPaths(other))
+		} else if opts.Hash {
+			if err := hashTree(otherDir, other); err != nil {
+				return err
+			}
+		}
 
-		// Remove leading slashes for relative patterns
-		pattern = strings.TrimPrefix(pattern, "/")
+		root = compareTrees(root, other)
 
-		// Handle directory-only patterns (ending with /)
-		pattern = strings.TrimSuffix(pattern, "/")
+		// Leave out what is the same in both
+		if opts.DiffOnly {
+			keepChanges(root)
+		}
+	}
 
-		patterns = append(patterns, pattern)
+	// Render only the focused subtree
+	if opts.Focus != "" {
+		root, err = focusTree(root, opts.Focus)
+		if err != nil {
+			return err
+		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	// Keep only the paths to the most deeply nested files
+	if opts.Deepest {
+		keepDeepest(root)
 	}
 
-	return patterns, nil
-}
+	// Note how deep each entry sits beneath the scan root
+	if opts.ShowDepth {
+		noteDepths(root)
+	}
 
-// shouldIgnore checks if a path should be ignored based on gitignore patterns
-func shouldIgnore(path string, isDir bool, patterns []string) bool {
-	for _, pattern := range patterns {
-		// Handle negated patterns
-		if strings.HasPrefix(pattern, "!") {
-			negatedPattern := strings.TrimPrefix(pattern, "!")
-			if match(path, negatedPattern, isDir) {
-				return false
+	// Read the start of small text files
+	if opts.Preview > 0 && opts.ListReads {
+		var paths []string
+		walkNodes(root, func(n *Node) {
+			if previewed(n, opts.PreviewMaxSize) {
+				paths = append(paths, n.Path)
 			}
-			continue
+		})
+		reads.add(rootDir, paths)
+	} else if opts.Preview > 0 {
+		if err := loadPreviews(rootDir, root, opts.Preview, opts.PreviewMaxSize); err != nil {
+			return err
 		}
+	}
 
-		// Check if the pattern matches
-		if match(path, pattern, isDir) {
-			return true
+	// Order by size rather than name
+	if opts.Sort == "size" {
+		sortBySize(root)
+	}
+
+	// Apply the directories-first, case-insensitive preset
+	if opts.TreeSort {
+		sortChildren(root, treeSortLess)
+	}
+
+	// Group directories and files
+	if opts.DirsFirst || opts.FilesFirst {
+		groupChildren(root, opts.DirsFirst)
+	}
+
+	// Move the entries listed in the order file to the front, in that order
+	if opts.OrderFile != "" {
+		order, err := loadOrderFile(opts.OrderFile)
+		if err != nil {
+			return err
 		}
+		sortChildren(root, orderLess(order))
 	}
 
-	return false
-}
+	// List everything below the cut-off depth without further nesting
+	if opts.FlattenBelow > 0 {
+		flattenBelow(root, opts.FlattenBelow)
+	}
 
-// match checks if a path matches a gitignore pattern
-func match(path string, pattern string, isDir bool) bool {
-	// Convert gitignore glob pattern to Go's filepath.Match pattern
-	// This is a simplified implementation
+	// Directories hidden by -type-filter have no line in a text tree, so
+	// what they hold moves up to the nearest directory that is shown
+	if opts.textTree() {
+		liftFiltered(root)
+	}
 
-	// Handle directory wildcards (**)
-	if strings.Contains(pattern, "**") {
-		// Replace ** with a special marker
-		pattern = strings.Replace(pattern, "**", "[[RECURSIVE]]", -1)
+	// Trim wide directories, keeping the first children in sorted order or,
+	// with a seed, a reproducible random sample of them
+	if opts.MaxPerDir > 0 {
+		var rng *rand.Rand
+		if opts.SampleSeeded {
+			rng = rand.New(rand.NewSource(opts.SampleSeed))
+		}
+		limitChildren(root, opts.MaxPerDir, rng)
+	}
+
+	// Mark each entry's type with a glyph
+	if opts.Icons {
+		icons, err := loadIcons(opts.IconsFile)
+		if err != nil {
+			return err
+		}
+		addIcons(root, icons)
+	}
 
-		// Split both the pattern and path into components
-		patternParts := strings.Split(pattern, string(os.PathSeparator))
-		pathParts := strings.Split(path, string(os.PathSeparator))
+	// Stop once the files shown add up to the size budget
+	omitted := 0
+	if opts.SizeBudget > 0 {
+		omitted = applySizeBudget(root, opts.SizeBudget)
+	}
 
-		return recursiveMatch(pathParts, patternParts, 0, 0)
+	// Keep only the shallowest entries that fit the node budget
+	overBudget := 0
+	if opts.NodeBudget > 0 {
+		overBudget = applyNodeBudget(root, opts.NodeBudget)
 	}
 
-	// Simple matching using filepath.Match
-	matched, _ := filepath.Match(pattern, path)
-	if matched {
-		return true
+	// Report how long the scan of the disk took
+	if opts.Timing && opts.OCI == "" && !opts.GitTracked {
+		cached := ""
+		if opts.Cache {
+			cached = fmt.Sprintf(" (%d from cache)", report.cacheHits)
+		}
+		fmt.Fprintf(stderr, "Walked in %s: %d directories read%s, %d entries processed\n",
+			report.elapsed.Round(time.Microsecond), report.dirsRead, cached, report.entries)
 	}
 
-	// Check for partial path match
-	// e.g., if pattern is "build", it should match both "build" and "path/to/build"
-	return strings.HasSuffix(path, pattern) ||
-		strings.Contains(path, pattern+string(os.PathSeparator))
-}
+	// A tree with more entries than -auto-summary-over is only summarized
+	entries := countNodes(root) - 1
+	summaryOnly := opts.AutoSummaryOver > 0 && entries > opts.AutoSummaryOver
+
+	// Render the tree in the selected format
+	start := out.Len()
+	if summaryOnly {
+		// Leave the tree out
+	} else if opts.ListReads {
+		if opts.Dupes {
+			reads.add(rootDir, dupeCandidates(filesBySize(root)))
+		}
+		reads.render(out)
+	} else if opts.Compare != "" && opts.DiffFormat == "json" {
+		if err := renderDiffJSON(out, root); err != nil {
+			return err
+		}
+	} else if opts.Dupes {
+		groups, err := findDuplicates(rootDir, root)
+		if err != nil {
+			return err
+		}
+		renderDupes(out, groups)
+	} else if opts.DirSummary {
+		if err := renderDirSummary(out, root, opts.Sort == "size"); err != nil {
+			return err
+		}
+	} else if opts.TemplateFile != "" {
+		if err := renderTemplateFile(out, root, opts.TemplateFile); err != nil {
+			return err
+		}
+	} else if opts.Slog {
+		renderSlog(out, root, !opts.Deterministic)
+	} else if opts.Pathspec {
+		renderPathspec(out, root)
+	} else if opts.Flat {
+		if prefix := renderFlat(out, root, opts); prefix != "" {
+			fmt.Fprintf(stderr, "Note: trimmed the common prefix %s\n", prefix)
+		}
+	} else {
+		switch opts.Format {
+		case "plist":
+			renderPlist(out, root)
+		case "ndjson":
+			if err := renderNDJSON(out, root); err != nil {
+				return err
+			}
+		case "paths-json":
+			if err := renderPathsJSON(out, root); err != nil {
+				return err
+			}
+		case "prototext":
+			renderPrototext(out, root)
+		case "sitemap":
+			renderSitemap(out, root, opts.BaseURL, opts.SitemapLastmod)
+		case "json":
+			if opts.JSONStream {
+				if err := renderJSONStream(out, root, opts.Head); err != nil {
+					return err
+				}
+			} else if err := renderJSON(out, root, opts.JSONPaths); err != nil {
+				return err
+			}
+		default:
+			// Give each directory at -section-depth its own section, after
+			// the top of the tree
+			trees := []*Node{root}
+			if opts.SectionDepth > 0 {
+				top, sections := splitSections(root, opts.SectionDepth)
+				trees = append([]*Node{top}, sections...)
+			}
 
-// recursiveMatch handles ** pattern matching
-func recursiveMatch(path, pattern []string, pathIdx, patternIdx int) bool {
-	// End conditions
-	if patternIdx >= len(pattern) {
-		return pathIdx >= len(path)
+			for i, tree := range trees {
+				if i > 0 {
+					out.WriteByte('\n')
+				}
+				if opts.PlainIndent {
+					renderPlainIndent(out, tree, opts.IndentWidth, opts.DirSlash)
+				} else {
+					renderText(out, tree, opts)
+				}
+			}
+		}
 	}
 
-	if pathIdx >= len(path) {
-		// Check if remaining patterns are all **
-		for i := patternIdx; i < len(pattern); i++ {
-			if pattern[i] != "[[RECURSIVE]]" {
-				return false
+	// Follow the tree with notes and summaries, writing them to stderr when
+	// they would break machine-readable output
+	var notes io.Writer = out
+	if !plainOutput(opts) {
+		notes = stderr
+	}
+
+	// Keep only the first -head lines, or -json-stream entries
+	if opts.Head > 0 {
+		if opts.JSONStream {
+			if total := countNodes(root) - 1; total > opts.Head {
+				fmt.Fprintf(stderr, "Note: -head kept the first %d of %d entries\n", opts.Head, total)
+			}
+		} else if dropped := truncateLines(out, start, opts.Head); dropped > 0 {
+			// A marker line would be read as another path or record
+			if opts.Flat || opts.Pathspec {
+				fmt.Fprintf(stderr, "Note: -head left out %d more lines\n", dropped)
+			} else {
+				fmt.Fprintf(notes, "... (%d more lines)\n", dropped)
 			}
 		}
-		return true
 	}
 
-	// Handle ** pattern
-	if pattern[patternIdx] == "[[RECURSIVE]]" {
-		// Try to match at current position or skip this path component
-		return recursiveMatch(path, pattern, pathIdx+1, patternIdx) ||
-			recursiveMatch(path, pattern, pathIdx, patternIdx+1) ||
-			recursiveMatch(path, pattern, pathIdx+1, patternIdx+1)
+	// Make it clear that the filters left nothing to show, beneath the
+	// header of a text tree and on stderr when the output is a list or data
+	if !opts.Quiet && countNodes(root) == 1 {
+		if plainOutput(opts) && !opts.Flat && !opts.Pathspec {
+			fmt.Fprintln(out, "(no matching entries)")
+		} else {
+			fmt.Fprintf(stderr, "Note: %s has no matching entries\n", root.Name)
+		}
 	}
 
-	// Regular pattern matching
-	match, _ := filepath.Match(pattern[patternIdx], path[pathIdx])
-	if match {
-		return recursiveMatch(path, pattern, pathIdx+1, patternIdx+1)
+	// Say that the size budget cut the tree short
+	if omitted > 0 {
+		fmt.Fprintf(notes, "(size budget of %s reached, %d more entries not shown)\n", formatSize(opts.SizeBudget), omitted)
 	}
+	if overBudget > 0 {
+		fmt.Fprintf(notes, "(node budget of %d reached, %d more entries not shown)\n", opts.NodeBudget, overBudget)
+	}
+
+	// Explain the markers the options add to the tree
+	if opts.Legend {
+		writeLegend(notes, legendEntries(root, opts))
+	}
+
+	// Say why only the summary follows
+	if summaryOnly {
+		fmt.Fprintf(notes, "%s has %d entries, more than -auto-summary-over %d; showing only the summary\n", root.Name, entries, opts.AutoSummaryOver)
+	}
+
+	// Count the entries shown by type
+	if (opts.CountByType || summaryOnly) && !opts.NoSummary {
+		fmt.Fprintln(notes, countTypes(root))
+	}
+
+	// Report how deeply the entries shown are nested
+	if opts.MaxDepthReport && !opts.NoSummary {
+		fmt.Fprintf(notes, "Max depth: %d\n", maxDepth(root))
+	}
+
+	return nil
+}
 
-	return false
+// truncateLines cuts what was written to buf from start on down to its first
+// n lines, returning how many lines were dropped
+func truncateLines(buf *bytes.Buffer, start, n int) int {
+	data := buf.Bytes()[start:]
+
+	end := 0
+	for i := 0; i < n; i++ {
+		next := bytes.IndexByte(data[end:], '\n')
+		if next < 0 {
+			return 0
+		}
+		end += next + 1
+	}
+
+	dropped := bytes.Count(data[end:], []byte("\n"))
+	if len(data) > end && data[len(data)-1] != '\n' {
+		dropped++
+	}
+	buf.Truncate(start + end)
+	return dropped
+}
+
+// plainOutput reports whether the selected output is a text tree or path
+// list that notes can be appended to
+func plainOutput(opts *Options) bool {
+	if opts.ListReads {
+		return false
+	}
+	if opts.Flat || opts.Pathspec {
+		return true
+	}
+	return opts.treeFormat() && opts.TemplateFile == "" && !opts.Dupes && !opts.DirSummary && opts.DiffFormat == "text" && !opts.Slog
 }
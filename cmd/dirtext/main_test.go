@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// makeTree creates a fixture in a new directory named "root" and returns its
+// path. Each entry is a '/'-separated path: one ending in '/' is a
+// directory, "name -> target" is a symlink and anything else is an empty
+// file. Parent directories are created as needed, and an empty .gitignore
+// keeps the scan from warning about a missing one.
+func makeTree(t testing.TB, entries ...string) string {
+	t.Helper()
+
+	root := filepath.Join(t.TempDir(), "root")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		name, target, isLink := strings.Cut(entry, " -> ")
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		var err error
+		switch {
+		case isLink:
+			err = os.Symlink(filepath.FromSlash(target), path)
+		case strings.HasSuffix(name, "/"):
+			err = os.MkdirAll(path, 0o755)
+		default:
+			err = os.WriteFile(path, nil, 0o644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+// writeFile creates the file at the '/'-separated path beneath root with
+// the given contents
+func writeFile(t testing.TB, root, name, contents string) {
+	t.Helper()
+
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runDirtext runs dirtext with the given arguments, returning what it wrote
+// to stdout and stderr and the error it failed with, if any
+func runDirtext(t *testing.T, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+
+	var out, errOut bytes.Buffer
+	opts, err := parseFlags(args, &errOut)
+	if err == nil {
+		err = run(opts, &out, &errOut)
+	}
+	return out.String(), errOut.String(), err
+}
+
+// render runs dirtext with the given arguments and returns its stdout,
+// failing the test if it doesn't succeed
+func render(t *testing.T, args ...string) string {
+	t.Helper()
+
+	stdout, stderr, err := runDirtext(t, args...)
+	if err != nil {
+		t.Fatalf("dirtext %s: %v\n%s", strings.Join(args, " "), err, stderr)
+	}
+	return stdout
+}
+
+// lines joins its arguments into newline-terminated output, for expected
+// trees
+func lines(l ...string) string {
+	return strings.Join(l, "\n") + "\n"
+}
+
+// chdir changes the working directory for the rest of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestNoTrailingNewline(t *testing.T) {
+	root := makeTree(t, "a.txt", "b/c.txt")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "root\n├── a.txt\n└── b\n    └── c.txt\n"},
+		{[]string{"-no-trailing-newline"}, "root\n├── a.txt\n└── b\n    └── c.txt"},
+		{[]string{"-no-trailing-newline", "-flat"}, "a.txt\nb\nb/c.txt"},
+		{[]string{"-no-trailing-newline", "-fence"}, "```\nroot\n├── a.txt\n└── b\n    └── c.txt\n```"},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestGlobRoots(t *testing.T) {
+	base := makeTree(t, "src/b/testdata/y", "src/a/testdata/x", "src/c/file")
+	for _, dir := range []string{"src/a/testdata", "src/b/testdata"} {
+		writeFile(t, base, dir+"/.gitignore", "")
+	}
+	glob := func(pattern string) string {
+		return filepath.Join(base, filepath.FromSlash(pattern))
+	}
+
+	// Matches are rendered in sorted order
+	want := lines(
+		"testdata",
+		"└── x",
+		"",
+		"testdata",
+		"└── y",
+	)
+	if got := render(t, "-glob-roots", glob("src/*/testdata")); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Without -glob-roots the pattern is taken literally
+	if _, _, err := runDirtext(t, glob("src/*/testdata")); err == nil {
+		t.Error("a literal glob root was expanded")
+	}
+
+	for _, pattern := range []string{"src/*/file", "nope*", "src/["} {
+		if _, _, err := runDirtext(t, "-glob-roots", glob(pattern)); err == nil {
+			t.Errorf("-glob-roots %s was accepted", pattern)
+		}
+	}
+}
+
+func TestOutputFileNotListed(t *testing.T) {
+	root := makeTree(t, "a.txt", "out/")
+	want := lines(
+		"root",
+		"├── a.txt",
+		"└── out",
+	)
+
+	// The file is left out however -o spells it, also when an earlier run
+	// left it there
+	chdir(t, root)
+	for _, output := range []string{
+		filepath.Join(root, "out", "tree.txt"),
+		filepath.Join("out", "tree.txt"),
+		filepath.Join(".", "out", "..", "out", "tree.txt"),
+	} {
+		for range 2 {
+			if stdout := render(t, "-o", output, root); stdout != "" {
+				t.Errorf("-o %s also wrote to stdout:\n%s", output, stdout)
+			}
+			got, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("-o %s:\ngot:\n%s\nwant:\n%s", output, got, want)
+			}
+		}
+	}
+
+	// Only that file: another with the same name elsewhere is listed
+	writeFile(t, root, "tree.txt", "")
+	render(t, "-o", filepath.Join("out", "tree.txt"), root)
+	if got, err := os.ReadFile(filepath.Join(root, "out", "tree.txt")); err != nil || !strings.Contains(string(got), "── tree.txt") {
+		t.Errorf("tree.txt in the root wasn't listed: %v\n%s", err, got)
+	}
+}
+
+func TestEmptyTreeNote(t *testing.T) {
+	empty := makeTree(t)
+	filtered := makeTree(t, "a.txt", "dir/b.txt")
+
+	tests := []struct {
+		root           string
+		args           []string
+		stdout, stderr string
+	}{
+		{empty, nil, lines("root", "(no matching entries)"), ""},
+		{filtered, []string{"-ext", "go"}, lines("root", "(no matching entries)"), ""},
+		{filtered, []string{"-ext", "go", "-plain-indent"}, lines("root", "(no matching entries)"), ""},
+		{filtered, []string{"-ext", "go", "-quiet"}, lines("root"), ""},
+		// Other outputs stay valid, with the note on stderr
+		{empty, []string{"-flat"}, "", lines("Note: root has no matching entries")},
+		{filtered, []string{"-ext", "go", "-format", "paths-json"}, "[]\n", lines("Note: root has no matching entries")},
+		{filtered, []string{"-ext", "go", "-format", "ndjson", "-quiet"}, "", ""},
+	}
+
+	for _, tt := range tests {
+		stdout, stderr, err := runDirtext(t, append(tt.args, tt.root)...)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if stdout != tt.stdout || stderr != tt.stderr {
+			t.Errorf("%v:\nstdout %q, want %q\nstderr %q, want %q", tt.args, stdout, tt.stdout, stderr, tt.stderr)
+		}
+	}
+}
+
+func TestHeadMarker(t *testing.T) {
+	root := makeTree(t, "a", "b", "c", "d", "e")
+
+	tests := []struct {
+		args           []string
+		stdout, stderr string
+	}{
+		{nil, lines("root", "├── a", "├── b", "... (3 more lines)"), ""},
+		{[]string{"-plain-indent"}, lines("root", "  a", "  b", "... (3 more lines)"), ""},
+		// Line-oriented output keeps every line a path or record
+		{[]string{"-flat"}, lines("a", "b", "c"), lines("Note: -head left out 2 more lines")},
+		{[]string{"-pathspec"}, lines("a", "b", "c"), lines("Note: -head left out 2 more lines")},
+		{[]string{"-format", "ndjson"}, lines(
+			`{"path":"a","isDir":false,"size":0}`,
+			`{"path":"b","isDir":false,"size":0}`,
+			`{"path":"c","isDir":false,"size":0}`,
+		), lines("... (2 more lines)")},
+		// Nothing to mark when everything fits
+		{[]string{"-flat", "-head", "5"}, lines("a", "b", "c", "d", "e"), ""},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-head", "3"}, tt.args...)
+		stdout, stderr, err := runDirtext(t, append(args, root)...)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if stdout != tt.stdout || stderr != tt.stderr {
+			t.Errorf("%v:\nstdout:\n%s\nwant:\n%s\nstderr %q, want %q", tt.args, stdout, tt.stdout, stderr, tt.stderr)
+		}
+	}
+}
+
+func TestHeadPerRoot(t *testing.T) {
+	a := makeTree(t, "1", "2", "3", "4")
+	b := makeTree(t, "x", "y")
+
+	// Each root is cut on its own, with its own marker
+	want := lines(
+		"root",
+		"├── 1",
+		"├── 2",
+		"... (2 more lines)",
+		"",
+		"root",
+		"├── x",
+		"└── y",
+	)
+	if got := render(t, "-head", "3", a, b); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	stdout, stderr, err := runDirtext(t, "-head", "1", "-flat", a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := lines("1", "", "x"); stdout != want {
+		t.Errorf("-flat: stdout %q, want %q", stdout, want)
+	}
+	if want := lines("Note: -head left out 3 more lines", "Note: -head left out 1 more lines"); stderr != want {
+		t.Errorf("-flat: stderr %q, want %q", stderr, want)
+	}
+}
+
+func TestAutoSummary(t *testing.T) {
+	root := makeTree(t, "a", "dir/b", "dir/c")
+	note := "root has 4 entries, more than -auto-summary-over 3; showing only the summary"
+
+	tests := []struct {
+		args           []string
+		stdout, stderr string
+	}{
+		// At the threshold the tree is still rendered in full
+		{[]string{"-auto-summary-over", "4"}, lines(
+			"root",
+			"├── a",
+			"└── dir",
+			"    ├── b",
+			"    └── c",
+		), ""},
+		{[]string{"-auto-summary-over", "3"}, lines(note, "3 files, 1 dir, 0 symlinks"), ""},
+		{[]string{"-auto-summary-over", "3", "-max-depth-report"}, lines(note, "3 files, 1 dir, 0 symlinks", "Max depth: 2"), ""},
+		// Entries are counted after limits
+		{[]string{"-auto-summary-over", "3", "-max-per-dir", "1"}, lines(
+			"root",
+			"├── a",
+			"└── ... (1 more)",
+		), ""},
+		// Structured formats keep stdout empty
+		{[]string{"-auto-summary-over", "3", "-format", "json"}, "", lines(note, "3 files, 1 dir, 0 symlinks")},
+	}
+
+	for _, tt := range tests {
+		stdout, stderr, err := runDirtext(t, append(tt.args, root)...)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if stdout != tt.stdout || stderr != tt.stderr {
+			t.Errorf("%v:\nstdout:\n%s\nwant:\n%s\nstderr %q, want %q", tt.args, stdout, tt.stdout, stderr, tt.stderr)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-auto-summary-over", "-1"},
+		{"-auto-summary-over", "3", "-no-summary"},
+	} {
+		if _, _, err := runDirtext(t, append(args, root)...); err == nil {
+			t.Errorf("%v was accepted", args)
+		}
+	}
+}
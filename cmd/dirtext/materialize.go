@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// treeLine is one entry parsed from a text tree
+type treeLine struct {
+	number int
+	depth  int
+	name   string
+	isDir  bool
+}
+
+// materialize reads a text tree, as drawn by dirtext with the connectors in
+// opts, and creates its directories and empty files inside targetDir. It
+// never overwrites existing files.
+func materialize(treeFile, targetDir string, opts *Options, stderr io.Writer) error {
+	file, err := openReadOnly(treeFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entries, err := parseTextTree(file, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", treeFile, err)
+	}
+
+	// Work out every entry's path. parents holds the path of the directory
+	// at each depth on the way to the current entry.
+	var parents []string
+	paths := make([]string, len(entries))
+	for i, e := range entries {
+		parents = append(parents[:e.depth], "")
+		parents[e.depth] = e.name
+		if e.depth > 0 {
+			parents[e.depth] = filepath.Join(parents[e.depth-1], e.name)
+		}
+		paths[i] = filepath.Join(targetDir, parents[e.depth])
+	}
+
+	// Check for conflicts before creating anything, so a failed run leaves
+	// the target untouched
+	for i, e := range entries {
+		info, err := os.Lstat(paths[i])
+		if err == nil && (!e.isDir || !info.IsDir()) {
+			return fmt.Errorf("%s: line %d: %s already exists", treeFile, e.number, paths[i])
+		}
+	}
+
+	dirs, files := 0, 0
+	for i, e := range entries {
+		if e.isDir {
+			if err := os.MkdirAll(paths[i], 0o755); err != nil {
+				return err
+			}
+			dirs++
+			continue
+		}
+
+		f, err := os.OpenFile(paths[i], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		files++
+	}
+
+	fmt.Fprintf(stderr, "Created %d directories and %d files in %s\n", dirs, files, targetDir)
+	return nil
+}
+
+// parseTextTree parses the lines of one or more text trees separated by blank
+// lines. Each tree starts with its root's name; the entries below it are
+// placed by their connectors. An entry with children, a [...] marker or a
+// name ending in '/' is a directory and every other entry is a file.
+func parseTextTree(r io.Reader, opts *Options) ([]treeLine, error) {
+	var entries []treeLine
+	newTree := true
+
+	scanner := bufio.NewScanner(r)
+	number := 0
+	for scanner.Scan() {
+		number++
+		line := strings.TrimRight(scanner.Text(), " \r")
+
+		// A blank line ends a tree, and the next line is another root
+		if line == "" {
+			newTree = true
+			continue
+		}
+
+		if newTree {
+			text, _ := stripAnnotations(strings.TrimPrefix(line, opts.RootConnector))
+			name, err := entryName(text, number)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, treeLine{number: number, name: name, isDir: true})
+			newTree = false
+			continue
+		}
+
+		// Count the guides before the branch to find the entry's depth
+		depth := 1
+		rest := line
+		for {
+			if strings.HasPrefix(rest, opts.MidGuide) {
+				rest = rest[len(opts.MidGuide):]
+			} else if strings.HasPrefix(rest, opts.EmptyGuide) {
+				rest = rest[len(opts.EmptyGuide):]
+			} else {
+				break
+			}
+			depth++
+		}
+
+		var ok bool
+		if rest, ok = strings.CutPrefix(rest, opts.MidBranch); !ok {
+			if rest, ok = strings.CutPrefix(rest, opts.LastBranch); !ok {
+				return nil, fmt.Errorf("line %d: expected a tree branch such as %q", number, opts.MidBranch)
+			}
+		}
+
+		// Skip the -max-per-dir marker
+		if strings.HasPrefix(rest, "... (") && strings.HasSuffix(rest, " more)") {
+			continue
+		}
+
+		parent := entries[len(entries)-1]
+		if depth > parent.depth+1 {
+			return nil, fmt.Errorf("line %d: indented %d levels below the entry above it", number, depth-parent.depth)
+		}
+
+		text, collapsed := stripAnnotations(rest)
+		name, err := entryName(text, number)
+		if err != nil {
+			return nil, err
+		}
+		if depth == parent.depth+1 {
+			entries[len(entries)-1].isDir = true
+		}
+
+		isDir := collapsed || strings.HasSuffix(text, "/")
+		entries = append(entries, treeLine{number: number, depth: depth, name: name, isDir: isDir})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no tree found")
+	}
+
+	return entries, nil
+}
+
+// stripAnnotations removes what displayCells adds after an entry's name, from
+// the right: the [...] marker, which only directories have, notes and hashes
+// in parentheses and a symlink's "-> target". Cells may be separated by more
+// than one space, as with -align-columns.
+func stripAnnotations(text string) (name string, collapsed bool) {
+	text = strings.TrimRight(text, " ")
+	if rest, ok := strings.CutSuffix(text, " [...]"); ok {
+		text, collapsed = strings.TrimRight(rest, " "), true
+	}
+
+	for strings.HasSuffix(text, ")") {
+		i := strings.LastIndex(text, " (")
+		if i < 0 {
+			break
+		}
+		text = strings.TrimRight(text[:i], " ")
+	}
+
+	if i := strings.Index(text, " -> "); i >= 0 {
+		text = strings.TrimRight(text[:i], " ")
+	}
+
+	return text, collapsed
+}
+
+// entryName checks that a name read from a tree is a single path element,
+// so a tree can't create anything outside the target directory
+func entryName(name string, number int) (string, error) {
+	name = strings.TrimSuffix(name, "/")
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("line %d: %q is not a valid file or directory name", number, name)
+	}
+	return name, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStripAnnotations(t *testing.T) {
+	tests := []struct {
+		text      string
+		name      string
+		collapsed bool
+	}{
+		{"main.go", "main.go", false},
+		{"dir/", "dir/", false},
+		{"vendor [...]", "vendor", true},
+		{"link -> ../target", "link", false},
+		{"a.txt (sha256:87428fc52280)", "a.txt", false},
+		{"a.txt (sha256:87428fc52280) (added)", "a.txt", false},
+		{"link -> a.txt (depth 2)", "link", false},
+		{"node_modules (changed) [...]", "node_modules", true},
+		{"a.txt   (sha256:87428fc52280)  (added)", "a.txt", false},
+		{"a (b).txt", "a (b).txt", false},
+		{"(empty)", "(empty)", false},
+	}
+
+	for _, tt := range tests {
+		name, collapsed := stripAnnotations(tt.text)
+		if name != tt.name || collapsed != tt.collapsed {
+			t.Errorf("stripAnnotations(%q) = %q, %v, want %q, %v", tt.text, name, collapsed, tt.name, tt.collapsed)
+		}
+	}
+}
+
+func TestMaterializeRoundTrip(t *testing.T) {
+	root := makeTree(t, "cmd/main.go", "cmd/old.go", "docs/", "link -> cmd/main.go", "vendor/lib/x.go")
+	old := makeTree(t, "cmd/old.go", "gone.txt")
+
+	// Each tree comes back with its collapsed directories empty and its
+	// symlinks as files
+	want := lines(
+		"root",
+		"├── cmd",
+		"│   ├── main.go",
+		"│   └── old.go",
+		"├── docs",
+		"├── link",
+		"└── vendor",
+	)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, want},
+		{[]string{"-link-targets", "-hash"}, want},
+		{[]string{"-link-targets", "-hash", "-align-columns"}, want},
+		// -compare also draws the entries only in the other tree
+		{[]string{"-show-depth", "-compare", old}, lines(
+			"root",
+			"├── cmd",
+			"│   ├── main.go",
+			"│   └── old.go",
+			"├── docs",
+			"├── gone.txt",
+			"├── link",
+			"└── vendor",
+		)},
+	}
+
+	for _, tt := range tests {
+		tree := filepath.Join(t.TempDir(), "tree.txt")
+		render(t, append(append([]string{"-o", tree}, tt.args...), root)...)
+
+		target := t.TempDir()
+		render(t, "-materialize", tree, target)
+
+		if got := render(t, "-no-default-leaves", filepath.Join(target, "root")); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestParseTextTreeCollapsed(t *testing.T) {
+	opts, err := parseFlags([]string{"-materialize", "tree.txt"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A [...] entry is a directory even without children
+	got, err := parseTextTree(strings.NewReader(lines(
+		"root",
+		"├── node_modules [...]",
+		"└── main.go (sha256:87428fc52280)",
+	)), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []treeLine{
+		{number: 1, name: "root", isDir: true},
+		{number: 2, depth: 1, name: "node_modules", isDir: true},
+		{number: 3, depth: 1, name: "main.go"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// Thresholds of -suspicious-mtime
+var (
+	// earliestMtime is the start of the day after the ZIP and FAT epoch.
+	// Older times, such as the Unix epoch, are almost always placeholders
+	// written by tools that dropped the real one.
+	earliestMtime = time.Date(1980, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+	// futureSlack is how far ahead of the clock an mtime may be before it
+	// counts as clock skew rather than time zone trouble
+	futureSlack = 24 * time.Hour
+
+	// medianSpread is how far from the median mtime of the tree's files an
+	// mtime may be before the file looks copied in from elsewhere
+	medianSpread = 10 * 365 * 24 * time.Hour
+)
+
+// mtimeWarning is a file with an implausible modification time
+type mtimeWarning struct {
+	path   string
+	mtime  time.Time
+	reason string
+}
+
+// suspiciousMtimes returns the files of the tree whose modification time is
+// before earliestMtime, more than futureSlack after now, or more than
+// medianSpread away from the median of all files, in display order
+func suspiciousMtimes(root *Node, now time.Time) []mtimeWarning {
+	var files []*Node
+	walkNodes(root, func(n *Node) {
+		if n.Mode.IsRegular() && !n.ModTime.IsZero() {
+			files = append(files, n)
+		}
+	})
+	if len(files) == 0 {
+		return nil
+	}
+
+	// Unlike the mean, the median isn't dragged off by the outliers it is
+	// used to find
+	times := make([]time.Time, len(files))
+	for i, n := range files {
+		times[i] = n.ModTime
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	median := times[len(times)/2]
+
+	var warnings []mtimeWarning
+	for _, n := range files {
+		var reason string
+		switch {
+		case n.ModTime.Before(earliestMtime):
+			reason = "before 1980"
+		case n.ModTime.After(now.Add(futureSlack)):
+			reason = "in the future"
+		case n.ModTime.Sub(median) > medianSpread || median.Sub(n.ModTime) > medianSpread:
+			reason = "more than 10 years from the median of " + median.UTC().Format(time.DateOnly)
+		default:
+			continue
+		}
+		warnings = append(warnings, mtimeWarning{n.Path, n.ModTime, reason})
+	}
+	return warnings
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSuspiciousMtimes(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	file := func(name string, mtime time.Time) *Node {
+		return &Node{Name: name, Path: name, ModTime: mtime}
+	}
+	tree := func(files ...*Node) *Node {
+		return &Node{Name: "root", IsDir: true, Children: files}
+	}
+	recent := now.Add(-time.Hour)
+	median := " from the median of 2026-10-14"
+
+	tests := []struct {
+		root *Node
+		want []string
+	}{
+		{tree(file("a", recent), file("b", recent)), nil},
+		// Each threshold and the times just inside it
+		{tree(
+			file("epoch", time.Unix(0, 0)),
+			file("zip", time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)),
+			file("skewed", now.Add(futureSlack+time.Minute)),
+			file("tomorrow", now.Add(futureSlack-time.Minute)),
+			file("r1", recent), file("r2", recent), file("r3", recent), file("r4", recent),
+		), []string{"epoch: before 1980", "zip: before 1980", "skewed: in the future"}},
+		// In an old tree the median is old too
+		{tree(
+			file("after-zip", earliestMtime),
+			file("r1", time.Date(1985, 1, 1, 0, 0, 0, 0, time.UTC)),
+			file("r2", time.Date(1985, 1, 1, 0, 0, 0, 0, time.UTC)),
+		), nil},
+		{tree(
+			file("old", recent.Add(-medianSpread-time.Hour)),
+			file("oldish", recent.Add(-medianSpread+time.Hour)),
+			file("r1", recent), file("r2", recent),
+		), []string{"old: more than 10 years" + median}},
+		// Only regular files with a time count, so directories don't move
+		// the median
+		{tree(
+			&Node{Name: "dir", Path: "dir", IsDir: true, Mode: os.ModeDir, ModTime: time.Unix(0, 0)},
+			file("unknown", time.Time{}),
+			file("a", recent),
+		), nil},
+		{tree(), nil},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, w := range suspiciousMtimes(tt.root, now) {
+			got = append(got, w.path+": "+w.reason)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSuspiciousMtimeFlag(t *testing.T) {
+	root := makeTree(t, "a", "b", "c", "d")
+	epoch := time.Unix(0, 0)
+	if err := os.Chtimes(filepath.Join(root, "b"), epoch, epoch); err != nil {
+		t.Fatal(err)
+	}
+
+	want := lines("Warning: suspicious mtime on b: 1970-01-01T00:00:00Z (before 1980)")
+	stdout, stderr, err := runDirtext(t, "-suspicious-mtime", root)
+	if err != nil || stderr != want {
+		t.Errorf("stderr:\n%s\nwant:\n%s\nerror %v", stderr, want, err)
+	}
+	if !strings.Contains(stdout, "── b") {
+		t.Errorf("no tree:\n%s", stdout)
+	}
+
+	// -strict fails after writing the tree
+	stdout, _, err = runDirtext(t, "-suspicious-mtime", "-strict", root)
+	if err == nil || err.Error() != "1 suspicious mtime found" {
+		t.Errorf("-strict: error %v", err)
+	}
+	if !strings.Contains(stdout, "── b") {
+		t.Errorf("-strict: no tree:\n%s", stdout)
+	}
+
+	// Filters decide what is checked
+	if _, stderr, err := runDirtext(t, "-suspicious-mtime", "-strict", "-exclude", "/b", root); err != nil || stderr != "" {
+		t.Errorf("filtered: %v\n%s", err, stderr)
+	}
+}
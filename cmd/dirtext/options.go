@@ -0,0 +1,865 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Options holds the settings resolved from the command line
+type Options struct {
+	// Roots are the directories to scan, from the positional arguments
+	Roots []string `json:"roots"`
+
+	// GlobRoots expands each root argument as a glob, scanning every
+	// directory it matches
+	GlobRoots bool `json:"globRoots"`
+
+	// Deterministic pins every setting that could make the output differ
+	// between runs or machines
+	Deterministic bool `json:"deterministic"`
+
+	// RepoRoot scans from the top of the enclosing git repository instead of
+	// the current directory
+	RepoRoot bool `json:"repoRoot"`
+
+	// Fetch is a git URL to shallow-clone into a temporary directory and
+	// render instead of a local root
+	Fetch string `json:"fetch"`
+
+	// GoModuleRoot scans from the directory of the enclosing go.mod, naming
+	// the root after the module path
+	GoModuleRoot bool `json:"goModuleRoot"`
+
+	// TypeFilter restricts printed entries to the given find(1)-style types:
+	// f (files), d (directories) and l (symlinks)
+	TypeFilter string `json:"typeFilter"`
+
+	// Include limits the files shown to those matching at least one of these
+	// gitignore-style patterns
+	Include []string `json:"include"`
+
+	// Require lists gitignore-style patterns that some entry must match, or
+	// dirtext exits with an error
+	Require []string `json:"require"`
+
+	// CheckCaseCollisions warns about entries of a directory whose names
+	// differ only in case
+	CheckCaseCollisions bool `json:"checkCaseCollisions"`
+
+	// SuspiciousMtime warns about files with implausible modification times
+	SuspiciousMtime bool `json:"suspiciousMtime"`
+
+	// Strict turns the warnings of CheckCaseCollisions and SuspiciousMtime
+	// into a failing exit status
+	Strict bool `json:"strict"`
+
+	// Exclude drops entries matching any of these gitignore-style patterns
+	Exclude []string `json:"exclude"`
+
+	// NewerThanFile limits the files shown to those modified after this
+	// reference file, like make's dependency check
+	NewerThanFile string `json:"newerThanFile"`
+
+	// ExcludeAbs drops entries whose absolute path matches any of these
+	// globs, wherever the scan root is
+	ExcludeAbs []string `json:"excludeAbs"`
+
+	// Ext limits the files shown to those with one of these extensions
+	Ext []string `json:"ext"`
+
+	// LeafDirs are directory names shown without their contents, on top of
+	// the built-in list unless NoDefaultLeaves is set. Names starting with
+	// '!' are removed from the list instead.
+	LeafDirs        []string `json:"leafDirs"`
+	NoDefaultLeaves bool     `json:"noDefaultLeaves"`
+
+	// Python adds the caches and build output of Python tooling to the leaf
+	// directories
+	Python bool `json:"python"`
+
+	// CodeOnly skips binary and asset files by extension, using BinaryExt
+	// instead of the built-in list if it is set
+	CodeOnly  bool     `json:"codeOnly"`
+	BinaryExt []string `json:"binaryExt"`
+
+	// GitTracked shows only the files tracked by git instead of walking
+	// the disk
+	GitTracked bool `json:"gitTracked"`
+
+	// BlameAuthor notes the author of the last commit to each tracked file
+	BlameAuthor bool `json:"blameAuthor"`
+
+	// ExistingOnly drops tracked paths missing from the working tree
+	ExistingOnly bool `json:"existingOnly"`
+
+	// OCI renders the filesystem of a `docker save` or OCI image tarball
+	// instead of scanning the disk
+	OCI string `json:"oci"`
+
+	// OCILayers limits the image view to its first N layers; 0 merges all of
+	// them
+	OCILayers int `json:"ociLayers"`
+
+	// SkipSpecial omits sockets, devices and named pipes
+	SkipSpecial bool `json:"skipSpecial"`
+
+	// LinkTargets prints symlinks as "name -> target"
+	LinkTargets bool `json:"linkTargets"`
+
+	// RelativeLinks displays absolute symlink targets inside the scan root
+	// relative to the link; it implies LinkTargets
+	RelativeLinks bool `json:"relativeLinks"`
+
+	// Sort orders entries by name (the default) or by size, largest first
+	Sort string `json:"sort"`
+
+	// DirsFirst and FilesFirst list directories before files, or files
+	// before directories, within each directory
+	DirsFirst  bool `json:"dirsFirst"`
+	FilesFirst bool `json:"filesFirst"`
+
+	// TreeSort orders each directory's children directories first, then by
+	// case-insensitive name
+	TreeSort bool `json:"treeSort"`
+
+	// OrderFile lists relative paths, one per line, to show first and in
+	// that order, ahead of the entries sorted as usual
+	OrderFile string `json:"orderFile"`
+
+	// MaxPerDir limits how many children are printed per directory
+	MaxPerDir int `json:"maxPerDir"`
+
+	// Head limits the output of each root to its first N lines, or entries
+	// for -json-stream
+	Head int `json:"head"`
+
+	// SampleSeed makes -max-per-dir keep a random sample of each
+	// directory's children, the same for the same seed, instead of the first
+	// ones. SampleSeeded records that it was given.
+	SampleSeed   int64 `json:"sampleSeed"`
+	SampleSeeded bool  `json:"sampleSeeded"`
+
+	// SizeBudget stops the tree, in display order, once the files shown would
+	// add up to more than this many bytes
+	SizeBudget int64 `json:"sizeBudget"`
+
+	// NodeBudget keeps only this many entries, the shallowest first
+	NodeBudget int `json:"nodeBudget"`
+
+	// Preview prints the first N lines of small text files beneath them
+	Preview int `json:"preview"`
+
+	// PreviewMaxSize is the largest file, in bytes, that -preview shows
+	PreviewMaxSize int64 `json:"previewMaxSize"`
+
+	// Format selects the output format: text, gh-details, json, ndjson,
+	// paths-json, plist, prototext or sitemap
+	Format string `json:"format"`
+
+	// JSONPaths adds each node's relative path to -format json output
+	JSONPaths bool `json:"jsonPaths"`
+
+	// JSONStream writes -format json as a flat array of entries, encoded one
+	// at a time, instead of a nested document
+	JSONStream bool `json:"jsonStream"`
+
+	// TemplateFile renders the tree with a text/template loaded from this
+	// file instead of a built-in format
+	TemplateFile string `json:"templateFile"`
+
+	// RootConnector is printed before the root's name
+	RootConnector string `json:"rootConnector"`
+
+	// MidBranch, LastBranch, MidGuide and EmptyGuide are the connectors the
+	// text tree is drawn with
+	MidBranch  string `json:"midBranch"`
+	LastBranch string `json:"lastBranch"`
+	MidGuide   string `json:"midGuide"`
+	EmptyGuide string `json:"emptyGuide"`
+
+	// Slog writes each entry as a log/slog JSON record instead of the tree
+	Slog bool `json:"slog"`
+
+	// Pathspec prints each file as a git pathspec, quoted for
+	// --pathspec-from-file
+	Pathspec bool `json:"pathspec"`
+
+	// PlainIndent draws the text tree with spaces only, IndentWidth per
+	// level, instead of connectors
+	PlainIndent bool `json:"plainIndent"`
+	IndentWidth int  `json:"indentWidth"`
+
+	// DirSlash appends '/' to directory names in -plain-indent output
+	DirSlash bool `json:"dirSlash"`
+
+	// FlattenBelow lists everything beneath the directories at this depth
+	// directly under them, named by its path from there
+	FlattenBelow int `json:"flattenBelow"`
+
+	// AlignColumns lines up the annotations of the text tree in columns
+	AlignColumns bool `json:"alignColumns"`
+
+	// RTL mirrors the text tree for right-to-left documents, drawing the
+	// connectors to the right of the names
+	RTL bool `json:"rtl"`
+
+	// Legend follows the text tree with a key to the markers the options
+	// add to it
+	Legend bool `json:"legend"`
+
+	// SectionDepth renders each directory at this depth as its own section,
+	// headed by its path, after the top of the tree
+	SectionDepth int `json:"sectionDepth"`
+
+	// Icons puts a Nerd Font glyph for each entry's type before its name,
+	// when the output goes to a terminal
+	Icons bool `json:"icons"`
+
+	// IconsFile is a JSON object of glyphs that add to or replace the
+	// -icons defaults
+	IconsFile string `json:"iconsFile"`
+
+	// Boxed frames the text tree in a border with the root's name as its
+	// title, when the output goes to a terminal
+	Boxed bool `json:"boxed"`
+
+	// Flat prints one relative path per line instead of a tree
+	Flat bool `json:"flat"`
+
+	// TrimCommonPrefix cuts the directory every -flat path shares off them
+	TrimCommonPrefix bool `json:"trimCommonPrefix"`
+
+	// NativeSep writes -flat paths with the platform's separator instead of
+	// '/'
+	NativeSep bool `json:"nativeSep"`
+
+	// Number prefixes each flat line with its position
+	Number bool `json:"number"`
+
+	// DirSummary prints a table of directories with their sizes and file
+	// counts instead of the tree
+	DirSummary bool `json:"dirSummary"`
+
+	// Hardlinks notes files that share an inode
+	Hardlinks bool `json:"hardlinks"`
+
+	// Hash computes the SHA-256 of every file, showing it in the text output
+	// and using it to detect changes with -compare
+	Hash bool `json:"hash"`
+
+	// Compare merges in the tree of another directory, marking entries as
+	// added, removed, changed or common
+	Compare string `json:"compare"`
+
+	// DiffFormat selects the -compare output: an annotated text tree or a
+	// JSON list of statuses
+	DiffFormat string `json:"diffFormat"`
+
+	// DiffOnly leaves the entries -compare finds unchanged out of the tree,
+	// except for the directories holding changes
+	DiffOnly bool `json:"diffOnly"`
+
+	// Focus renders only the subtree of this directory, relative to the
+	// scan root
+	Focus string `json:"focus"`
+
+	// ShowDepth notes each entry's depth beneath the scan root, which with
+	// -focus differs from its indentation
+	ShowDepth bool `json:"showDepth"`
+
+	// Deepest shows only the paths leading to the most deeply nested files
+	Deepest bool `json:"deepest"`
+
+	// Dupes reports groups of files with identical contents and the space
+	// that removing the extra copies would reclaim
+	Dupes bool `json:"dupes"`
+
+	// NoEscapeRoot refuses to reveal symlink targets outside the scan root,
+	// marking such links "(outside root)" instead
+	NoEscapeRoot bool `json:"noEscapeRoot"`
+
+	// ListReads prints the files that -hash, -preview and -dupes would read
+	// instead of reading them and rendering the tree
+	ListReads bool `json:"listReads"`
+
+	// DetailsSummary is the title of the -format gh-details section
+	DetailsSummary string `json:"detailsSummary"`
+
+	// BaseURL prefixes the paths of -format sitemap
+	BaseURL string `json:"baseURL"`
+
+	// SitemapLastmod adds each file's mtime to -format sitemap
+	SitemapLastmod bool `json:"sitemapLastmod"`
+
+	// Gzip compresses the output, written to the -o file or a pipe
+	Gzip bool `json:"gzip"`
+
+	// Fence wraps the output in a Markdown fenced code block, labelled with
+	// FenceLang if it is set
+	Fence     bool   `json:"fence"`
+	FenceLang string `json:"fenceLang"`
+
+	// NoTrailingNewline drops the newline after the last line of output
+	NoTrailingNewline bool `json:"noTrailingNewline"`
+
+	// Cache reuses directory listings from previous runs for directories
+	// whose mtime hasn't changed, storing them in CacheDir
+	Cache    bool   `json:"cache"`
+	CacheDir string `json:"cacheDir"`
+
+	// CountByType follows the tree with a count of the entries shown by
+	// type, unless NoSummary turns summary lines off
+	CountByType bool `json:"countByType"`
+	NoSummary   bool `json:"noSummary"`
+
+	// AutoSummaryOver replaces the tree with its summary lines when it has
+	// more than this many entries
+	AutoSummaryOver int `json:"autoSummaryOver"`
+
+	// MaxDepthReport follows the tree with the depth of its most deeply
+	// nested entry
+	MaxDepthReport bool `json:"maxDepthReport"`
+
+	// Output is the file the output is written to instead of stdout
+	Output string `json:"output"`
+
+	// NoTemp skips common editor temporary and backup files
+	NoTemp bool `json:"noTemp"`
+
+	// Quiet leaves out the message for a tree with no matching entries
+	Quiet bool `json:"quiet"`
+
+	// Materialize creates the directories and empty files of this text tree
+	// instead of scanning
+	Materialize string `json:"materialize"`
+
+	// Pager pipes the output through $PAGER when stdout is a terminal
+	Pager bool `json:"pager"`
+
+	// ErrorsJSON collects the errors for unreadable entries into this file
+	// instead of stopping the scan
+	ErrorsJSON string `json:"errorsJSON"`
+
+	// Timing reports the walk time and amount of work done to stderr
+	Timing bool `json:"timing"`
+
+	// DumpOptions prints the resolved options as JSON to stderr before
+	// scanning
+	DumpOptions bool `json:"dumpOptions"`
+}
+
+// parseFlags parses the command line arguments into Options
+func parseFlags(args []string, stderr io.Writer) (*Options, error) {
+	opts := &Options{}
+
+	fs := flag.NewFlagSet("dirtext", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: dirtext [flags] [dir ...]")
+		fs.PrintDefaults()
+	}
+
+	fs.BoolVar(&opts.GlobRoots, "glob-roots", false,
+		"expand root arguments as globs, e.g. 'src/*/testdata', rendering each matched directory")
+	fs.BoolVar(&opts.Deterministic, "deterministic", false,
+		"produce byte-identical output across runs and machines")
+	fs.BoolVar(&opts.RepoRoot, "repo-root", false,
+		"scan from the root of the enclosing git repository")
+	fs.StringVar(&opts.Fetch, "fetch", "",
+		"shallow-clone the git repository at this `url` into a temporary directory and render it")
+	fs.BoolVar(&opts.GoModuleRoot, "go-module-root", false,
+		"scan from the directory of the enclosing go.mod, labeled with its module path")
+	fs.StringVar(&opts.TypeFilter, "type-filter", "",
+		"only print entries of these types: f (files), d (directories), l (symlinks), e.g. fl")
+	fs.Var((*stringList)(&opts.Include), "include",
+		"only show files matching this pattern; a leading / anchors it to the scan root (repeatable)")
+	fs.Var((*stringList)(&opts.Require), "require",
+		"exit with an error if no entry matches this pattern, e.g. LICENSE (repeatable)")
+	fs.BoolVar(&opts.CheckCaseCollisions, "check-case-collisions", false,
+		"warn about names in one directory that differ only in case")
+	fs.BoolVar(&opts.SuspiciousMtime, "suspicious-mtime", false,
+		"warn about files modified before 1980, in the future or far from the rest of the tree")
+	fs.BoolVar(&opts.Strict, "strict", false,
+		"with -check-case-collisions or -suspicious-mtime, exit with an error if they warn")
+	fs.Var((*stringList)(&opts.Exclude), "exclude",
+		"skip entries matching this pattern; a leading / anchors it to the scan root (repeatable)")
+	fs.StringVar(&opts.NewerThanFile, "newer-than-file", "",
+		"only show files modified after this reference `file`")
+	fs.Var((*stringList)(&opts.ExcludeAbs), "exclude-abs",
+		"skip entries whose absolute path matches this glob, e.g. /home/*/cache (repeatable)")
+	fs.Var((*stringList)(&opts.Ext), "ext",
+		"only show files with this extension, e.g. .go or go,md (repeatable)")
+	fs.Var((*stringList)(&opts.LeafDirs), "leaf-dirs",
+		"show directories with these names, e.g. dist,build, without their contents; !name expands one again (repeatable)")
+	fs.BoolVar(&opts.NoDefaultLeaves, "no-default-leaves", false,
+		"expand node_modules, vendor, target and the other built-in leaf directories")
+	fs.BoolVar(&opts.Python, "python", false,
+		"also show __pycache__, .pytest_cache, .tox and other Python tooling directories without their contents")
+	fs.BoolVar(&opts.CodeOnly, "code-only", false,
+		"skip images, archives, compiled objects and other binary files by extension")
+	fs.Var((*stringList)(&opts.BinaryExt), "binary-ext",
+		"with -code-only, skip these extensions instead of the built-in list (repeatable)")
+	fs.BoolVar(&opts.GitTracked, "git-tracked", false,
+		"only show files tracked by git")
+	fs.BoolVar(&opts.BlameAuthor, "blame-author", false,
+		"note the author of the last commit to each tracked file (slow on long histories)")
+	fs.BoolVar(&opts.ExistingOnly, "existing-only", false,
+		"with -git-tracked, only show tracked files that exist on disk")
+	fs.StringVar(&opts.OCI, "oci", "",
+		"render the filesystem of a docker save or OCI image `tarball`")
+	fs.IntVar(&opts.OCILayers, "oci-layers", 0,
+		"with -oci, only merge the first N layers (0 merges all)")
+	fs.BoolVar(&opts.SkipSpecial, "skip-special", true,
+		"skip sockets, devices and named pipes (use -skip-special=false to show them)")
+	fs.BoolVar(&opts.LinkTargets, "link-targets", false,
+		"show symlink targets as name -> target")
+	fs.BoolVar(&opts.RelativeLinks, "relative-links", false,
+		"show absolute symlink targets inside the scan root relative to the link (implies -link-targets)")
+	fs.StringVar(&opts.Sort, "sort", "name",
+		"order entries by name or size (largest first)")
+	fs.BoolVar(&opts.DirsFirst, "dirs-first", false,
+		"list directories before files")
+	fs.BoolVar(&opts.FilesFirst, "files-first", false,
+		"list files before directories")
+	fs.BoolVar(&opts.TreeSort, "tree-sort", false,
+		"list directories first, then entries by case-insensitive name")
+	fs.StringVar(&opts.OrderFile, "order-file", "",
+		"file of relative paths, one per line, to list first in that order")
+	fs.IntVar(&opts.MaxPerDir, "max-per-dir", 0,
+		"print at most N children per directory, noting how many were left out (0 for no limit)")
+	fs.IntVar(&opts.Head, "head", 0,
+		"only output the first N lines of each root, or entries with -json-stream, marking the cut (0 for no limit)")
+	fs.Int64Var(&opts.SampleSeed, "sample-seed", 0,
+		"with -max-per-dir, keep a random sample of each directory, reproducible with the same `seed`")
+	fs.Var((*byteSize)(&opts.SizeBudget), "size-budget",
+		"stop once the files shown add up to this `size`, e.g. 10M (0 shows everything)")
+	fs.IntVar(&opts.NodeBudget, "node-budget", 0,
+		"show at most N entries, keeping the shallowest and marking the rest [...] (0 shows everything)")
+	fs.IntVar(&opts.Preview, "preview", 0,
+		"print the first N lines of small text files beneath them")
+	fs.Int64Var(&opts.PreviewMaxSize, "preview-max-size", 16384,
+		"largest file, in bytes, that -preview shows")
+	fs.StringVar(&opts.Format, "format", "text",
+		"output format: text, gh-details, json, ndjson, paths-json, plist, prototext or sitemap")
+	fs.BoolVar(&opts.JSONPaths, "json-paths", false,
+		"include each node's relative path in -format json output")
+	fs.BoolVar(&opts.JSONStream, "json-stream", false,
+		"with -format json, write a flat array of {path, isDir, size} entries encoded one at a time")
+	fs.StringVar(&opts.TemplateFile, "template-file", "",
+		"render the tree with the text/template in this `file`")
+	fs.StringVar(&opts.RootConnector, "root-connector", "",
+		"marker printed before the root's name, e.g. \"┌ \" to join it to its children")
+	fs.StringVar(&opts.MidBranch, "mid-branch", "├── ",
+		"connector before an entry with more siblings below it")
+	fs.StringVar(&opts.LastBranch, "last-branch", "└── ",
+		"connector before the last entry of a directory")
+	fs.StringVar(&opts.MidGuide, "mid-guide", "│   ",
+		"indentation below an entry with more siblings below it")
+	fs.StringVar(&opts.EmptyGuide, "empty-guide", "    ",
+		"indentation below the last entry of a directory")
+	fs.BoolVar(&opts.Slog, "slog", false,
+		"write each entry as a structured log/slog JSON record (path, depth, isDir) instead of the tree")
+	fs.BoolVar(&opts.Pathspec, "pathspec", false,
+		"print each file as a git pathspec, for git add --pathspec-from-file=-")
+	fs.BoolVar(&opts.PlainIndent, "plain-indent", false,
+		"indent the tree with spaces only, without connectors")
+	fs.IntVar(&opts.IndentWidth, "indent-width", 2,
+		"spaces per level for -plain-indent")
+	fs.BoolVar(&opts.DirSlash, "dir-slash", false,
+		"with -plain-indent, end directory names with /")
+	fs.IntVar(&opts.FlattenBelow, "flatten-below", 0,
+		"list everything beneath the directories at depth N under them by path, without nesting (0 to nest fully)")
+	fs.BoolVar(&opts.AlignColumns, "align-columns", false,
+		"line up symlink targets, hashes, notes and [...] markers in columns across the text tree")
+	fs.BoolVar(&opts.RTL, "rtl", false,
+		"mirror the text tree for right-to-left documents, with the connectors on the right")
+	fs.BoolVar(&opts.Legend, "legend", false,
+		"end the text tree with a key to the annotations and markers in use")
+	fs.IntVar(&opts.SectionDepth, "section-depth", 0,
+		"render each directory at depth N as its own section headed by its path (0 for one tree)")
+	fs.BoolVar(&opts.Icons, "icons", false,
+		"on a terminal, put a Nerd Font glyph for each entry's type before its name")
+	fs.StringVar(&opts.IconsFile, "icons-file", "",
+		"JSON object of extension to glyph mappings that add to or replace the -icons defaults")
+	fs.BoolVar(&opts.Boxed, "boxed", false,
+		"on a terminal, frame the tree in a border with the root's name as its title")
+	fs.BoolVar(&opts.Flat, "flat", false,
+		"print one relative path per line instead of a tree")
+	fs.BoolVar(&opts.TrimCommonPrefix, "trim-common-prefix", false,
+		"cut the directory every -flat path shares off them, noting it on stderr")
+	fs.BoolVar(&opts.NativeSep, "native-sep", false,
+		"write -flat paths with the platform's separator instead of /")
+	fs.BoolVar(&opts.Number, "number", false,
+		"number each line of -flat output")
+	fs.BoolVar(&opts.DirSummary, "dir-summary", false,
+		"print one line per directory with its entry count, file count and size")
+	fs.BoolVar(&opts.Hardlinks, "hardlinks", false,
+		"note which files are hard links to the same inode (Unix only)")
+	fs.BoolVar(&opts.Hash, "hash", false,
+		"show the SHA-256 of each file; with -compare, detect changes by content")
+	fs.StringVar(&opts.Compare, "compare", "",
+		"compare against this `dir`, marking entries added, removed or changed")
+	fs.BoolVar(&opts.DiffOnly, "diff-only", false,
+		"with -compare, only show added, removed and changed entries and the directories holding them")
+	fs.StringVar(&opts.DiffFormat, "diff-format", "text",
+		"-compare output: text (annotated tree) or json (list of path and status)")
+	fs.StringVar(&opts.Focus, "focus", "",
+		"only render the subtree of this `dir`, relative to the scan root")
+	fs.BoolVar(&opts.ShowDepth, "show-depth", false,
+		"note each entry's depth beneath the scan root, also with -focus")
+	fs.BoolVar(&opts.Deepest, "deepest", false,
+		"only show the paths to the most deeply nested files, noting their depth")
+	fs.BoolVar(&opts.Dupes, "dupes", false,
+		"report files with identical contents and the potential savings")
+	fs.BoolVar(&opts.NoEscapeRoot, "no-escape-root", false,
+		"don't reveal symlink targets outside the scan root; mark them (outside root)")
+	fs.BoolVar(&opts.ListReads, "list-reads", false,
+		"print the files -hash, -preview and -dupes would read, without reading them")
+	fs.StringVar(&opts.DetailsSummary, "details-summary", "Project structure",
+		"title of the collapsible -format gh-details section")
+	fs.StringVar(&opts.BaseURL, "base-url", "",
+		"`URL` prefixed to the paths of -format sitemap, e.g. https://example.com/docs")
+	fs.BoolVar(&opts.SitemapLastmod, "sitemap-lastmod", false,
+		"include each file's mtime as <lastmod> in -format sitemap")
+	fs.BoolVar(&opts.Gzip, "gzip", false,
+		"compress the output with gzip, for -o or a pipe")
+	fs.Var(fenceFlag{opts}, "fence",
+		"wrap the output in a Markdown code fence, optionally labelled (-fence=text)")
+	fs.BoolVar(&opts.NoTrailingNewline, "no-trailing-newline", false,
+		"don't end the output with a newline")
+	fs.BoolVar(&opts.Cache, "cache", false,
+		"reuse directory listings from previous runs when a directory's mtime is unchanged")
+	fs.StringVar(&opts.CacheDir, "cache-dir", defaultCacheDir(),
+		"where -cache stores its listings (implies -cache when set)")
+	fs.BoolVar(&opts.CountByType, "count-by-type", false,
+		"end with a count of the files, directories, symlinks and other entries shown")
+	fs.BoolVar(&opts.MaxDepthReport, "max-depth-report", false,
+		"end with the maximum nesting depth of the entries shown")
+	fs.IntVar(&opts.AutoSummaryOver, "auto-summary-over", 0,
+		"with more than N entries, print only the summary lines instead of the tree (0 for no limit)")
+	fs.BoolVar(&opts.NoSummary, "no-summary", false,
+		"don't print summary lines such as -count-by-type's and -max-depth-report's")
+	fs.StringVar(&opts.Output, "o", "",
+		"write the output to this `file` instead of stdout; it is never listed in the tree")
+	fs.BoolVar(&opts.NoTemp, "no-temp", false,
+		"skip editor temporary and backup files such as *~, *.swp and #*#")
+	fs.BoolVar(&opts.Quiet, "quiet", false,
+		"don't say when filters leave no matching entries")
+	fs.StringVar(&opts.Materialize, "materialize", "",
+		"create the directories and empty files drawn in this text tree `file` inside the given dir (default .)")
+	fs.BoolVar(&opts.Pager, "pager", false,
+		"show the output through $PAGER (or less) when writing to a terminal")
+	fs.StringVar(&opts.ErrorsJSON, "errors-json", "",
+		"skip unreadable entries and write their errors as JSON to this `file`")
+	fs.BoolVar(&opts.Timing, "timing", false,
+		"report the walk time, directories read and entries processed to stderr")
+	fs.BoolVar(&opts.DumpOptions, "dump-options", false,
+		"print the resolved options as JSON to stderr")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	opts.Roots = fs.Args()
+
+	if opts.RelativeLinks {
+		opts.LinkTargets = true
+	}
+
+	opts.resolve()
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "cache-dir":
+			opts.Cache = true
+		case "sample-seed":
+			opts.SampleSeeded = true
+		}
+	})
+
+	// The flag package reports its own parse errors, but invalid
+	// combinations have to be reported here
+	if err := opts.validate(); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// resolve applies the settings pinned by -deterministic
+func (o *Options) resolve() {
+	if !o.Deterministic {
+		return
+	}
+
+	// Paths always use '/'
+	o.NativeSep = false
+
+	// Output never depends on whether stdout is a terminal
+	o.Pager = false
+}
+
+// validate checks for invalid combinations of options
+func (o *Options) validate() error {
+	switch o.Format {
+	case "text", "gh-details", "json", "ndjson", "paths-json", "plist", "prototext", "sitemap":
+	default:
+		return fmt.Errorf("unknown -format %q (want text, gh-details, json, ndjson, paths-json, plist, prototext or sitemap)", o.Format)
+	}
+
+	if o.JSONStream && o.Format != "json" {
+		return errors.New("-json-stream requires -format json")
+	}
+
+	if o.JSONStream && o.JSONPaths {
+		return errors.New("-json-stream always includes paths; drop -json-paths")
+	}
+
+	if o.JSONPaths && o.Format != "json" {
+		return errors.New("-json-paths requires -format json")
+	}
+
+	if o.Flat && o.Format != "text" {
+		return errors.New("-flat can only be used with -format text")
+	}
+
+	if o.OCI != "" && (o.Dupes || o.RepoRoot || o.GoModuleRoot || o.GitTracked || o.Preview > 0 || o.Hash || o.Hardlinks || o.BlameAuthor || len(o.ExcludeAbs) > 0 || len(o.Roots) > 0) {
+		return errors.New("-oci can't be combined with root directories, -dupes, -repo-root, -go-module-root, -git-tracked, -preview, -hash, -hardlinks, -blame-author or -exclude-abs")
+	}
+
+	for _, pattern := range o.ExcludeAbs {
+		if !filepath.IsAbs(pattern) && !strings.HasPrefix(pattern, "/") {
+			return fmt.Errorf("-exclude-abs pattern %q is not an absolute path", pattern)
+		}
+	}
+
+	if o.Preview < 0 {
+		return errors.New("-preview can't be negative")
+	}
+
+	if o.TemplateFile != "" && (o.Flat || o.Format != "text") {
+		return errors.New("-template-file can't be combined with -flat or -format")
+	}
+
+	if o.ExistingOnly && !o.GitTracked {
+		return errors.New("-existing-only requires -git-tracked")
+	}
+
+	switch o.Sort {
+	case "name", "size":
+	default:
+		return fmt.Errorf("unknown -sort %q (want name or size)", o.Sort)
+	}
+
+	switch o.DiffFormat {
+	case "text", "json":
+	default:
+		return fmt.Errorf("unknown -diff-format %q (want text or json)", o.DiffFormat)
+	}
+
+	if o.DiffFormat != "text" && o.Compare == "" {
+		return errors.New("-diff-format requires -compare")
+	}
+
+	if o.DiffOnly && o.Compare == "" {
+		return errors.New("-diff-only requires -compare")
+	}
+
+	if o.Compare != "" && (o.OCI != "" || o.GitTracked) {
+		return errors.New("-compare can't be combined with -oci or -git-tracked")
+	}
+
+	if o.ShowDepth && o.Deepest {
+		return errors.New("-show-depth can't be combined with -deepest, which notes depths already")
+	}
+
+	if o.TreeSort && (o.Sort != "name" || o.DirsFirst || o.FilesFirst) {
+		return errors.New("-tree-sort can't be combined with -sort size, -dirs-first or -files-first")
+	}
+
+	if len(o.BinaryExt) > 0 && !o.CodeOnly {
+		return errors.New("-binary-ext requires -code-only")
+	}
+
+	if o.Materialize != "" && (o.OCI != "" || len(o.Roots) > 1) {
+		return errors.New("-materialize takes at most one target directory and can't be combined with -oci")
+	}
+
+	if o.DirsFirst && o.FilesFirst {
+		return errors.New("-dirs-first and -files-first are mutually exclusive")
+	}
+
+	if o.Head < 0 {
+		return errors.New("-head can't be negative")
+	}
+
+	nested := (!o.treeFormat() && o.Format != "ndjson" && !o.JSONStream) || o.TemplateFile != "" || o.DiffFormat == "json"
+	if o.Head > 0 && nested {
+		return errors.New("-head can't cut nested output such as JSON or plist; use -format ndjson, -json-stream or -flat")
+	}
+
+	if o.SampleSeeded && o.MaxPerDir == 0 {
+		return errors.New("-sample-seed requires -max-per-dir")
+	}
+
+	if o.MaxPerDir < 0 {
+		return errors.New("-max-per-dir can't be negative")
+	}
+
+	if o.NodeBudget < 0 {
+		return errors.New("-node-budget can't be negative")
+	}
+
+	for _, connector := range []struct{ name, value string }{
+		{"mid-branch", o.MidBranch},
+		{"last-branch", o.LastBranch},
+		{"mid-guide", o.MidGuide},
+		{"empty-guide", o.EmptyGuide},
+	} {
+		if connector.value == "" {
+			return fmt.Errorf("-%s can't be empty", connector.name)
+		}
+	}
+
+	if o.Number && !o.Flat {
+		return errors.New("-number requires -flat")
+	}
+
+	if o.NativeSep && !o.Flat {
+		return errors.New("-native-sep requires -flat")
+	}
+
+	if o.ListReads && !o.Hash && o.Preview == 0 && !o.Dupes {
+		return errors.New("-list-reads requires -hash, -preview or -dupes")
+	}
+
+	if o.AutoSummaryOver < 0 {
+		return errors.New("-auto-summary-over can't be negative")
+	}
+
+	if o.AutoSummaryOver > 0 && o.NoSummary {
+		return errors.New("-auto-summary-over falls back to the summary, which -no-summary turns off")
+	}
+
+	if (o.BaseURL != "" || o.SitemapLastmod) && o.Format != "sitemap" {
+		return errors.New("-base-url and -sitemap-lastmod require -format sitemap")
+	}
+
+	if o.SitemapLastmod && o.Deterministic {
+		return errors.New("-sitemap-lastmod prints modification times, which -deterministic output can't depend on")
+	}
+
+	if o.Format == "gh-details" && o.Fence {
+		return errors.New("-format gh-details already fences the tree; drop -fence")
+	}
+
+	if o.Gzip && o.Pager {
+		return errors.New("-gzip and -pager are mutually exclusive")
+	}
+	if o.Gzip && (o.Fence || o.Format == "gh-details") {
+		return errors.New("-gzip can't be combined with -fence or -format gh-details, which wrap the whole output")
+	}
+
+	if o.TrimCommonPrefix && !o.Flat {
+		return errors.New("-trim-common-prefix requires -flat")
+	}
+
+	if o.Slog && (o.Pathspec || o.Flat || o.PlainIndent || o.Format != "text" || o.TemplateFile != "") {
+		return errors.New("-slog can't be combined with -pathspec, -flat, -plain-indent, -format or -template-file")
+	}
+
+	if o.Pathspec && (o.Flat || o.PlainIndent || o.Format != "text" || o.TemplateFile != "") {
+		return errors.New("-pathspec can't be combined with -flat, -plain-indent, -format or -template-file")
+	}
+
+	if o.Boxed && !o.textTree() {
+		return errors.New("-boxed only frames text trees")
+	}
+
+	if o.Strict && !o.CheckCaseCollisions && !o.SuspiciousMtime {
+		return errors.New("-strict requires -check-case-collisions or -suspicious-mtime")
+	}
+
+	if o.Fetch != "" && (len(o.Roots) > 0 || o.OCI != "" || o.GlobRoots || o.Materialize != "" || len(o.ExcludeAbs) > 0) {
+		return errors.New("-fetch renders the clone instead of root directories, and can't be combined with -oci, -glob-roots, -materialize or -exclude-abs")
+	}
+
+	if o.RepoRoot && o.GoModuleRoot {
+		return errors.New("-repo-root and -go-module-root are mutually exclusive")
+	}
+
+	if o.FlattenBelow < 0 {
+		return errors.New("-flatten-below can't be negative")
+	}
+
+	if o.FlattenBelow > 0 && !o.textTree() {
+		return errors.New("-flatten-below only applies to text trees")
+	}
+
+	if o.RTL && (!o.textTree() || o.PlainIndent) {
+		return errors.New("-rtl only mirrors the text tree, without -plain-indent")
+	}
+
+	if o.AlignColumns && (!o.textTree() || o.PlainIndent || o.RTL) {
+		return errors.New("-align-columns only lines up the connector tree, without -plain-indent or -rtl")
+	}
+
+	if o.Legend && !o.textTree() {
+		return errors.New("-legend only explains text trees")
+	}
+
+	if o.SectionDepth < 0 {
+		return errors.New("-section-depth can't be negative")
+	}
+
+	if o.SectionDepth > 0 && (!o.textTree() || o.Boxed) {
+		return errors.New("-section-depth only splits text trees, and can't be combined with -boxed")
+	}
+
+	if o.Icons && !o.textTree() {
+		return errors.New("-icons only decorates text trees")
+	}
+
+	if o.IconsFile != "" && !o.Icons {
+		return errors.New("-icons-file requires -icons")
+	}
+
+	if o.PlainIndent && (o.Flat || !o.treeFormat() || o.TemplateFile != "") {
+		return errors.New("-plain-indent can't be combined with -flat, -format or -template-file")
+	}
+
+	if o.IndentWidth < 1 {
+		return errors.New("-indent-width must be at least 1")
+	}
+
+	if o.DirSlash && !o.PlainIndent {
+		return errors.New("-dir-slash requires -plain-indent")
+	}
+
+	return nil
+}
+
+// dump writes the options as indented JSON
+func (o *Options) dump(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(o)
+}
+
+// textTree reports whether the output is the indented text tree, drawn with
+// connectors or with -plain-indent
+func (o *Options) textTree() bool {
+	return !o.Flat && !o.Pathspec && !o.Slog && o.treeFormat() && o.TemplateFile == "" && !o.Dupes && !o.DirSummary && o.DiffFormat == "text"
+}
+
+// treeFormat reports whether -format draws the text tree, on its own or
+// wrapped in a GitHub <details> section
+func (o *Options) treeFormat() bool {
+	return o.Format == "text" || o.Format == "gh-details"
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+// fenceFlag implements the -fence flag, which may be given bare (-fence) or
+// with a language label (-fence=go)
+type fenceFlag struct {
+	opts *Options
+}
+
+func (f fenceFlag) String() string {
+	if f.opts == nil || !f.opts.Fence {
+		return ""
+	}
+	return f.opts.FenceLang
+}
+
+func (f fenceFlag) Set(value string) error {
+	switch value {
+	case "true":
+		f.opts.Fence, f.opts.FenceLang = true, ""
+	case "false":
+		f.opts.Fence, f.opts.FenceLang = false, ""
+	default:
+		f.opts.Fence, f.opts.FenceLang = true, value
+	}
+	return nil
+}
+
+func (f fenceFlag) IsBoolFlag() bool { return true }
+
+// wrapInFence wraps content in a Markdown fenced code block. The fence is made
+// longer than any run of backticks in the content so filenames containing
+// backticks can't close it early.
+func wrapInFence(content []byte, lang string) []byte {
+	fence := strings.Repeat("`", max(3, longestBacktickRun(content)+1))
+
+	var buf bytes.Buffer
+	buf.WriteString(fence + lang + "\n")
+	buf.Write(content)
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(fence + "\n")
+
+	return buf.Bytes()
+}
+
+// wrapInDetails wraps content in a fenced code block inside a collapsible
+// GitHub <details> section titled summary. The blank lines around the fence
+// make GitHub render it as Markdown rather than raw HTML.
+func wrapInDetails(content []byte, summary string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("<details>\n<summary>" + html.EscapeString(summary) + "</summary>\n\n")
+	buf.Write(wrapInFence(content, ""))
+	buf.WriteString("\n</details>\n")
+
+	return buf.Bytes()
+}
+
+// longestBacktickRun returns the length of the longest run of consecutive
+// backticks in content
+func longestBacktickRun(content []byte) int {
+	longest, current := 0, 0
+	for _, b := range content {
+		if b == '`' {
+			current++
+			longest = max(longest, current)
+		} else {
+			current = 0
+		}
+	}
+	return longest
+}
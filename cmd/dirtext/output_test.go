@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestGHDetails(t *testing.T) {
+	root := makeTree(t, "a", "x```y")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// The fence is longer than the backticks in the tree
+		{nil, lines(
+			"<details>",
+			"<summary>Project structure</summary>",
+			"",
+			"````",
+			"root",
+			"├── a",
+			"└── x```y",
+			"````",
+			"",
+			"</details>",
+		)},
+		// The title is escaped, and summary lines go inside the block
+		{[]string{"-details-summary", "A <b> & c", "-count-by-type", "-plain-indent"}, lines(
+			"<details>",
+			"<summary>A &lt;b&gt; &amp; c</summary>",
+			"",
+			"````",
+			"root",
+			"  a",
+			"  x```y",
+			"2 files, 0 dirs, 0 symlinks",
+			"````",
+			"",
+			"</details>",
+		)},
+		{[]string{"-no-trailing-newline"}, "<details>\n<summary>Project structure</summary>\n\n````\nroot\n├── a\n└── x```y\n````\n\n</details>"},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-format", "gh-details"}, tt.args...)
+		if got := render(t, append(args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	if _, _, err := runDirtext(t, "-format", "gh-details", "-fence", root); err == nil {
+		t.Error("-format gh-details -fence was accepted")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// defaultPager is used by -pager when $PAGER isn't set
+const defaultPager = "less"
+
+// isTerminal reports whether w is a terminal rather than a file or pipe
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// page writes output through the user's pager ($PAGER, or less), which
+// shares the terminal on stdout. Quitting the pager before reading
+// everything isn't an error.
+func page(output []byte, stdout, stderr io.Writer) error {
+	command := strings.Fields(os.Getenv("PAGER"))
+	if len(command) == 0 {
+		command = []string{defaultPager}
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	// Let less exit straight away when the tree fits on one screen, unless
+	// the user configured it already
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// The pager closes its end of the pipe when the user quits early
+	_, err = stdin.Write(output)
+	if err != nil && !errors.Is(err, syscall.EPIPE) {
+		stdin.Close()
+		cmd.Wait()
+		return err
+	}
+	stdin.Close()
+
+	return cmd.Wait()
+}
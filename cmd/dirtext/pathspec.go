@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// renderPathspec writes the relative path of every file as a git pathspec,
+// one per line, in the form read by git's --pathspec-from-file
+func renderPathspec(w io.Writer, root *Node) {
+	walkNodes(root, func(n *Node) {
+		if !n.IsDir {
+			fmt.Fprintln(w, pathspecLine(n.Path))
+		}
+	})
+}
+
+// pathspecLine converts a path into a pathspec that matches only that path.
+// Git treats *, ? and [ as wildcards and \ as their escape, so those are
+// backslash-escaped. A leading ':' would start pathspec magic, so such paths
+// use the :(literal) magic instead, which turns off wildcards altogether.
+// Lines holding control characters or starting with a double quote are
+// C-quoted, as git's core.quotePath quoting does.
+func pathspecLine(path string) string {
+	spec := ":(literal)" + path
+	if !strings.HasPrefix(path, ":") {
+		var b strings.Builder
+		for _, r := range path {
+			if strings.ContainsRune(`*?[\`, r) {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		spec = b.String()
+	}
+
+	if !needsCQuote(spec) {
+		return spec
+	}
+	return cQuote(spec)
+}
+
+// needsCQuote reports whether a line must be C-quoted to be read back as is
+func needsCQuote(s string) bool {
+	if strings.HasPrefix(s, `"`) {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// cQuoteEscapes are the single-letter escapes git's C-style quoting uses
+var cQuoteEscapes = map[byte]string{
+	'\a': `\a`, '\b': `\b`, '\t': `\t`, '\n': `\n`,
+	'\v': `\v`, '\f': `\f`, '\r': `\r`, '"': `\"`, '\\': `\\`,
+}
+
+// cQuote quotes s the way git quotes paths: in double quotes, with \" and \\
+// escaped, control characters as C escapes or octal, and other bytes as is
+func cQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if esc, ok := cQuoteEscapes[c]; ok {
+			b.WriteString(esc)
+		} else if c < 0x20 || c == 0x7f {
+			fmt.Fprintf(&b, `\%03o`, c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPathspecLine(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"plain.go", "plain.go"},
+		{"sp ace", "sp ace"},
+		{"st*r", `st\*r`},
+		{"dir/a?b", `dir/a\?b`},
+		{"[x]", `\[x]`},
+		{`back\slash`, `back\\slash`},
+		{":colon", ":(literal):colon"},
+		{":st*r", ":(literal):st*r"},
+		{"nl\nx", `"nl\nx"`},
+		{"tab\tx", `"tab\tx"`},
+		{`"q`, `"\"q"`},
+		{"a\x01b", `"a\001b"`},
+	}
+
+	for _, tt := range tests {
+		if got := pathspecLine(tt.path); got != tt.want {
+			t.Errorf("pathspecLine(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPathspecGitAdd(t *testing.T) {
+	tricky := []string{
+		":colon",
+		"[x]",
+		`"q`,
+		"a?b",
+		`back\slash`,
+		"dir/st*r",
+		"nl\nx",
+		"sp ace",
+	}
+	// Each decoy would be matched too by one of the tricky names used as a
+	// glob
+	decoys := []string{"x", "a1b", "backslash", "dir/stXr"}
+	root := makeTree(t, append(append([]string(nil), tricky...), decoys...)...)
+	initRepo(t, root, append(append([]string(nil), tricky...), decoys...)...)
+
+	staged := func() []string {
+		t.Helper()
+		out := runGit(t, root, "", "diff", "--cached", "--name-only", "-z")
+		names := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+		if out == "" {
+			names = nil
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	// Each line selects exactly its own file
+	for _, path := range tricky {
+		runGit(t, root, pathspecLine(path)+"\n", "add", "--pathspec-from-file=-")
+		if got := staged(); !reflect.DeepEqual(got, []string{path}) {
+			t.Errorf("%q staged %q", pathspecLine(path), got)
+		}
+		runGit(t, root, "", "reset", "-q")
+	}
+
+	// The whole listing selects every file shown and nothing else
+	runGit(t, root, render(t, "-pathspec", "-exclude", "/{x,a1b,backslash,dir/stXr}", root), "add", "--pathspec-from-file=-")
+	want := append([]string(nil), tricky...)
+	sort.Strings(want)
+	if got := staged(); !reflect.DeepEqual(got, want) {
+		t.Errorf("staged %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// renderPlist writes the tree as an XML property list. Each node is a dict
+// with name and isDir keys; directories also have a children array.
+func renderPlist(w io.Writer, root *Node) {
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">`)
+	fmt.Fprintln(w, `<plist version="1.0">`)
+	renderPlistNode(w, root, 0)
+	fmt.Fprintln(w, `</plist>`)
+}
+
+// renderPlistNode writes one node and its children at the given indentation
+func renderPlistNode(w io.Writer, n *Node, depth int) {
+	indent := strings.Repeat("\t", depth)
+
+	fmt.Fprintf(w, "%s<dict>\n", indent)
+	fmt.Fprintf(w, "%s\t<key>name</key>\n", indent)
+	fmt.Fprintf(w, "%s\t<string>%s</string>\n", indent, xmlEscape(n.Name))
+	fmt.Fprintf(w, "%s\t<key>isDir</key>\n", indent)
+	fmt.Fprintf(w, "%s\t<%t/>\n", indent, n.IsDir)
+
+	if n.IsDir {
+		fmt.Fprintf(w, "%s\t<key>children</key>\n", indent)
+		children := visibleChildren(n)
+		if len(children) == 0 {
+			fmt.Fprintf(w, "%s\t<array/>\n", indent)
+		} else {
+			fmt.Fprintf(w, "%s\t<array>\n", indent)
+			for _, child := range children {
+				renderPlistNode(w, child, depth+2)
+			}
+			fmt.Fprintf(w, "%s\t</array>\n", indent)
+		}
+	}
+
+	fmt.Fprintf(w, "%s</dict>\n", indent)
+}
+
+// xmlEscape escapes s for use as XML character data
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// loadPreviews reads the first lines of every small text file in the tree.
+// Files larger than maxSize are skipped so previews stay short, as are binary
+// files, detected like git does by a NUL byte near the start.
+func loadPreviews(rootDir string, root *Node, lines int, maxSize int64) error {
+	var err error
+	walkNodes(root, func(n *Node) {
+		if err != nil || !previewed(n, maxSize) {
+			return
+		}
+		n.Preview, err = readPreview(filepath.Join(rootDir, filepath.FromSlash(n.Path)), lines, maxSize)
+
+		// Tracked files missing from disk have nothing to preview
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	})
+	return err
+}
+
+// previewed reports whether loadPreviews reads the file, which it does for
+// every regular file no larger than maxSize
+func previewed(n *Node, maxSize int64) bool {
+	return n.Mode.IsRegular() && n.Size <= maxSize
+}
+
+// readPreview returns up to lines lines of the file, or nil if it is binary
+func readPreview(path string, lines int, maxSize int64) ([]string, error) {
+	file, err := openReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil, nil
+	}
+
+	var preview []string
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if len(preview) == lines || line == "" {
+			break
+		}
+		preview = append(preview, strings.TrimRight(line, "\r\n"))
+	}
+
+	return preview, nil
+}
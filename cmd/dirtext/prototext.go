@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// renderPrototext writes the tree in protobuf text format, as an Entry
+// message of the suggested schema:
+//
+//	message Entry {
+//	  string name = 1;
+//	  bool is_dir = 2;
+//	  string link_target = 3;
+//	  repeated Entry children = 4;
+//	}
+//
+// link_target is only written for symlinks shown with -link-targets.
+func renderPrototext(w io.Writer, root *Node) {
+	renderPrototextFields(w, root, 0)
+}
+
+// renderPrototextFields writes the fields of one entry and its children at
+// the given indentation
+func renderPrototextFields(w io.Writer, n *Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	fmt.Fprintf(w, "%sname: %s\n", indent, cQuote(n.Name))
+	fmt.Fprintf(w, "%sis_dir: %t\n", indent, n.IsDir)
+	if n.LinkTarget != "" {
+		fmt.Fprintf(w, "%slink_target: %s\n", indent, cQuote(n.LinkTarget))
+	}
+
+	for _, child := range visibleChildren(n) {
+		fmt.Fprintf(w, "%schildren {\n", indent)
+		renderPrototextFields(w, child, depth+1)
+		fmt.Fprintf(w, "%s}\n", indent)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestPrototext(t *testing.T) {
+	root := makeTree(t, "dir/a", "ln -> dir/a", `we"ird\name`, "nl\nx", "日本")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-link-targets"}, lines(
+			`name: "root"`,
+			`is_dir: true`,
+			`children {`,
+			`  name: "dir"`,
+			`  is_dir: true`,
+			`  children {`,
+			`    name: "a"`,
+			`    is_dir: false`,
+			`  }`,
+			`}`,
+			`children {`,
+			`  name: "ln"`,
+			`  is_dir: false`,
+			`  link_target: "dir/a"`,
+			`}`,
+			`children {`,
+			`  name: "nl\nx"`,
+			`  is_dir: false`,
+			`}`,
+			`children {`,
+			`  name: "we\"ird\\name"`,
+			`  is_dir: false`,
+			`}`,
+			`children {`,
+			`  name: "日本"`,
+			`  is_dir: false`,
+			`}`,
+		)},
+		// Directories hidden by -type-filter give their children to the
+		// nearest shown entry
+		{[]string{"-type-filter", "f", "-include", "dir/**"}, lines(
+			`name: "root"`,
+			`is_dir: true`,
+			`children {`,
+			`  name: "a"`,
+			`  is_dir: false`,
+			`}`,
+		)},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-format", "prototext"}, tt.args...)
+		if got := render(t, append(args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import "os"
+
+// openedFile, when set, is called with the path of every file openReadOnly
+// opens. Tests use it to see what a run reads.
+var openedFile func(path string)
+
+// openReadOnly opens a file for reading only. dirtext must never modify the
+// tree it scans, so every file it reads is opened through here rather than
+// with os.OpenFile or os.Create.
+func openReadOnly(path string) (*os.File, error) {
+	if openedFile != nil {
+		openedFile(path)
+	}
+	return os.OpenFile(path, os.O_RDONLY, 0)
+}
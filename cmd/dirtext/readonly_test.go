@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContentOptionsLeaveFilesUnchanged(t *testing.T) {
+	root := makeTree(t, "empty.txt")
+	writeFile(t, root, "a.txt", "one\ntwo\nthree\n")
+	writeFile(t, root, "dir/b.txt", "one\ntwo\nthree\n")
+	writeFile(t, root, "dir/c.bin", "\x00\x01\x02")
+	if err := os.Chmod(filepath.Join(root, "dir", "c.bin"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pin every mtime in the past, so a write during the run would show
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	type state struct {
+		mode     fs.FileMode
+		modTime  time.Time
+		size     int64
+		contents string
+	}
+	snapshot := func() map[string]state {
+		states := make(map[string]state)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			s := state{mode: info.Mode(), modTime: info.ModTime(), size: info.Size()}
+			if !d.IsDir() {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				s.contents = string(data)
+			}
+			states[path] = s
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return states
+	}
+	for path := range snapshot() {
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := snapshot()
+
+	for _, args := range [][]string{
+		{"-hash"},
+		{"-preview", "2"},
+		{"-dupes"},
+		{"-hash", "-preview", "2", "-compare", root},
+	} {
+		render(t, append(args, root)...)
+
+		after := snapshot()
+		if len(after) != len(before) {
+			t.Errorf("%v: %d entries before, %d after", args, len(before), len(after))
+		}
+		for path, want := range before {
+			if got := after[path]; got != want {
+				t.Errorf("%v changed %s: %+v, want %+v", args, path, got, want)
+			}
+		}
+	}
+}
+
+func TestListReads(t *testing.T) {
+	root := makeTree(t, "a.txt", "dir/b.txt", "dir/same.txt", "empty.txt", "link -> a.txt")
+	writeFile(t, root, "a.txt", "one\n")
+	writeFile(t, root, "dir/b.txt", "two\n")
+	writeFile(t, root, "dir/same.txt", "one\n")
+	writeFile(t, root, "big.txt", strings.Repeat("x", 100))
+	other := makeTree(t, "c.txt")
+
+	// opens records the files a real run opens, apart from the .gitignore
+	// files read to build the tree
+	opens := func(args []string) []string {
+		t.Helper()
+		var paths []string
+		openedFile = func(path string) {
+			if filepath.Base(path) != ".gitignore" {
+				paths = append(paths, path)
+			}
+		}
+		defer func() { openedFile = nil }()
+		render(t, append(args, root)...)
+		return paths
+	}
+
+	for _, args := range [][]string{
+		{"-hash"},
+		{"-preview", "1"},
+		{"-preview", "1", "-preview-max-size", "10"},
+		{"-dupes"},
+		{"-hash", "-preview", "1"},
+		{"-hash", "-compare", other},
+		{"-hash", "-ext", "txt", "-exclude", "dir"},
+	} {
+		listed := strings.Fields(render(t, append(append([]string{"-list-reads"}, args...), root)...))
+		read := opens(args)
+
+		slices.Sort(listed)
+		want := slices.Compact(slices.Sorted(slices.Values(read)))
+		if !slices.Equal(listed, want) {
+			t.Errorf("%v: listed %q, read %q", args, listed, want)
+		}
+
+		// -list-reads itself opens nothing
+		if got := opens(append([]string{"-list-reads"}, args...)); len(got) != 0 {
+			t.Errorf("-list-reads %v opened %q", args, got)
+		}
+	}
+}
+
+// openedBy runs dirtext with the given arguments and returns the paths of
+// the files it opened through openReadOnly
+func openedBy(t *testing.T, args ...string) []string {
+	t.Helper()
+
+	var paths []string
+	openedFile = func(path string) { paths = append(paths, path) }
+	defer func() { openedFile = nil }()
+	render(t, args...)
+	return paths
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// fileReads collects the files -list-reads reports instead of reading them,
+// in the order they would be opened, each listed once
+type fileReads struct {
+	seen  map[string]bool
+	paths []string
+}
+
+func newFileReads() *fileReads {
+	return &fileReads{seen: make(map[string]bool)}
+}
+
+// add records the files at the '/'-separated paths relative to dir
+func (r *fileReads) add(dir string, paths []string) {
+	for _, p := range paths {
+		abs := filepath.Join(dir, filepath.FromSlash(p))
+		if !r.seen[abs] {
+			r.seen[abs] = true
+			r.paths = append(r.paths, abs)
+		}
+	}
+}
+
+// render writes one absolute path per line
+func (r *fileReads) render(w io.Writer) {
+	for _, p := range r.paths {
+		fmt.Fprintln(w, p)
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// displayName returns the name to print for a node, including its symlink
+// target and notes if any were recorded
+func displayName(n *Node) string {
+	return joinCells(displayCells(n))
+}
+
+// displayCells splits the line of a node into its name and one cell for each
+// kind of annotation: the symlink target, the hash, the notes and the
+// collapsed marker. Cells without an annotation are empty.
+func displayCells(n *Node) []string {
+	cells := []string{n.Name, "", "", "", ""}
+	if n.LinkTarget != "" {
+		cells[1] = "-> " + n.LinkTarget
+	}
+	if n.Hash != "" {
+		cells[2] = "(sha256:" + n.Hash[:12] + ")"
+	}
+	var notes []string
+	for _, note := range n.Notes {
+		notes = append(notes, "("+note+")")
+	}
+	cells[3] = strings.Join(notes, " ")
+	if n.Collapsed {
+		cells[4] = "[...]"
+	}
+	return cells
+}
+
+// joinCells joins the non-empty cells with single spaces
+func joinCells(cells []string) string {
+	var parts []string
+	for _, cell := range cells {
+		if cell != "" {
+			parts = append(parts, cell)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// connectors are the pieces a text tree is drawn with
+type connectors struct {
+	// root precedes the root's name
+	root string
+
+	// midBranch and lastBranch precede an entry that does or doesn't have
+	// siblings after it
+	midBranch  string
+	lastBranch string
+
+	// midGuide and emptyGuide continue, or don't, the line of an ancestor
+	// that does or doesn't have siblings after it
+	midGuide   string
+	emptyGuide string
+}
+
+// renderText writes the tree with box-drawing indentation, or with
+// opts.RTL mirrored so the connectors are on the right. With
+// opts.AlignColumns the annotations are lined up in columns.
+func renderText(w io.Writer, root *Node, opts *Options) {
+	c := connectors{
+		root:       opts.RootConnector,
+		midBranch:  opts.MidBranch,
+		lastBranch: opts.LastBranch,
+		midGuide:   opts.MidGuide,
+		emptyGuide: opts.EmptyGuide,
+	}
+
+	// Each line is drawn as its connectors, a gap and its cells of text
+	var lines []textLine
+	emit := func(prefix, gap string, cells ...string) {
+		if opts.RTL || opts.AlignColumns {
+			lines = append(lines, textLine{prefix, gap, cells})
+		} else {
+			fmt.Fprintln(w, prefix+gap+joinCells(cells))
+		}
+	}
+
+	// Print the root directory name
+	emit(c.root, "", root.Name)
+
+	renderTextChildren(emit, root, "", c)
+
+	if opts.RTL {
+		renderMirrored(w, lines)
+	} else if opts.AlignColumns {
+		renderAligned(w, lines)
+	}
+}
+
+// renderTextChildren emits the lines of the children of n, each starting
+// with prefix
+func renderTextChildren(emit func(prefix, gap string, cells ...string), n *Node, prefix string, c connectors) {
+	for i, child := range n.Children {
+		// The -max-per-dir marker, if any, comes after the last child
+		last := i == len(n.Children)-1 && n.More == 0
+
+		branch, guide := c.midBranch, c.midGuide
+		if last {
+			branch, guide = c.lastBranch, c.emptyGuide
+		}
+
+		// Print the tree branch and the file/directory name
+		emit(prefix+branch, "", displayCells(child)...)
+
+		// Print the file's preview indented beneath it, without trailing
+		// blanks
+		for _, line := range child.Preview {
+			if line = strings.TrimRight(line, " "); line != "" {
+				emit(prefix+guide, "  ", line)
+			} else {
+				emit(strings.TrimRight(prefix+guide, " "), "", "")
+			}
+		}
+
+		renderTextChildren(emit, child, prefix+guide, c)
+	}
+
+	// Say how many children -max-per-dir left out
+	if n.More > 0 {
+		emit(prefix+c.lastBranch, "", fmt.Sprintf("... (%d more)", n.More))
+	}
+}
+
+// columnGap separates the columns of -align-columns output
+const columnGap = "  "
+
+// renderAligned writes the lines with their cells lined up using elastic
+// tabstops: a cell that is followed by another on its line belongs to a
+// column shared with the same cell of the lines around it, and every cell
+// of such a run of lines is padded to the widest, measured in terminal
+// columns. The first cell includes the connectors, so annotations line up
+// whatever the depth. Columns left empty throughout a run take no space.
+func renderAligned(w io.Writer, lines []textLine) {
+	// Drop trailing empty cells, which start no column
+	rows := make([][]string, len(lines))
+	for i, l := range lines {
+		row := append([]string{l.prefix + l.gap + l.cells[0]}, l.cells[1:]...)
+		for len(row) > 1 && row[len(row)-1] == "" {
+			row = row[:len(row)-1]
+		}
+		rows[i] = row
+	}
+
+	// Size each column over every run of lines that has it
+	widths := make([][]int, len(rows))
+	for i, row := range rows {
+		widths[i] = make([]int, max(len(row)-1, 0))
+	}
+	for col := 0; ; col++ {
+		found := false
+		for start := 0; start < len(rows); {
+			if col >= len(rows[start])-1 {
+				start++
+				continue
+			}
+			found = true
+
+			end, width := start, 0
+			for ; end < len(rows) && col < len(rows[end])-1; end++ {
+				width = max(width, displayWidth(rows[end][col]))
+			}
+			for i := start; i < end; i++ {
+				widths[i][col] = width
+			}
+			start = end
+		}
+		if !found {
+			break
+		}
+	}
+
+	for i, row := range rows {
+		var b strings.Builder
+		for col, cell := range row {
+			if col == len(row)-1 {
+				b.WriteString(cell)
+				break
+			}
+			if widths[i][col] == 0 {
+				continue
+			}
+			b.WriteString(cell)
+			b.WriteString(strings.Repeat(" ", widths[i][col]-displayWidth(cell)))
+			b.WriteString(columnGap)
+		}
+		fmt.Fprintln(w, b.String())
+	}
+}
+
+// textLine is one line of a text tree: the connectors before the entry, the
+// gap after them and the cells of the entry's text
+type textLine struct {
+	prefix string
+	gap    string
+	cells  []string
+}
+
+// mirroredRunes maps box-drawing characters to their mirror images
+var mirroredRunes = map[rune]rune{
+	'├': '┤', '┤': '├',
+	'└': '┘', '┘': '└',
+	'┌': '┐', '┐': '┌',
+	'╭': '╮', '╮': '╭',
+	'╰': '╯', '╯': '╰',
+}
+
+// renderMirrored writes the lines right to left: each line's text comes
+// first and its connectors, reversed and mirrored, after it, with every line
+// padded on the left so the tree hangs from the right margin
+func renderMirrored(w io.Writer, lines []textLine) {
+	mirrored := make([]string, len(lines))
+	width := 0
+	for i, l := range lines {
+		mirrored[i] = joinCells(l.cells) + l.gap + mirror(l.prefix)
+		width = max(width, displayWidth(mirrored[i]))
+	}
+
+	for _, line := range mirrored {
+		fmt.Fprintln(w, strings.Repeat(" ", width-displayWidth(line))+line)
+	}
+}
+
+// mirror reverses s, replacing box-drawing characters with their mirror
+// images
+func mirror(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	for i, r := range runes {
+		if m, ok := mirroredRunes[r]; ok {
+			runes[i] = m
+		}
+	}
+	return string(runes)
+}
+
+// renderPlainIndent writes the tree with each entry indented by width spaces
+// per level and no connectors. If dirSlash is set directory names end in '/'.
+func renderPlainIndent(w io.Writer, root *Node, width int, dirSlash bool) {
+	fmt.Fprintln(w, root.Name)
+	renderPlainIndentChildren(w, root, 1, width, dirSlash)
+}
+
+func renderPlainIndentChildren(w io.Writer, n *Node, depth, width int, dirSlash bool) {
+	indent := strings.Repeat(" ", depth*width)
+	for _, child := range n.Children {
+		name := displayName(child)
+		if dirSlash && child.IsDir {
+			name = child.Name + "/" + strings.TrimPrefix(name, child.Name)
+		}
+		fmt.Fprintln(w, indent+name)
+
+		for _, line := range child.Preview {
+			fmt.Fprintln(w, strings.TrimRight(indent+strings.Repeat(" ", width)+line, " "))
+		}
+
+		renderPlainIndentChildren(w, child, depth+1, width, dirSlash)
+	}
+
+	if n.More > 0 {
+		fmt.Fprintf(w, "%s... (%d more)\n", indent, n.More)
+	}
+}
+
+// renderFlat writes one relative path per line. If opts.Number is set each
+// line is prefixed with its right-aligned position, like nl(1). With
+// opts.TrimCommonPrefix the directory all paths share is cut off, and
+// returned.
+func renderFlat(w io.Writer, root *Node, opts *Options) string {
+	sep := "/"
+	if opts.NativeSep {
+		sep = string(os.PathSeparator)
+	}
+
+	var paths []string
+	walkNodes(root, func(n *Node) {
+		if n != root && !n.Filtered {
+			paths = append(paths, n.Path)
+		}
+	})
+
+	prefix := ""
+	if opts.TrimCommonPrefix {
+		paths, prefix = trimCommonPrefix(paths)
+	}
+
+	width := len(fmt.Sprint(len(paths)))
+	for i, path := range paths {
+		path = withSeparator(path, sep)
+		if opts.Number {
+			fmt.Fprintf(w, "%*d  %s\n", width, i+1, path)
+		} else {
+			fmt.Fprintln(w, path)
+		}
+	}
+
+	return prefix
+}
+
+// trimCommonPrefix cuts the deepest directory holding every entry off paths,
+// which are in display order, returning the trimmed paths and the prefix
+// removed, ending in '/'. The lines of that directory and its parents, which
+// would be left empty, are dropped.
+func trimCommonPrefix(paths []string) ([]string, string) {
+	// Only the entries with nothing listed beneath them decide the prefix
+	var dir []string
+	first := true
+	for i, p := range paths {
+		if i+1 < len(paths) && strings.HasPrefix(paths[i+1], p+"/") {
+			continue
+		}
+
+		parts := strings.Split(p, "/")
+		parts = parts[:len(parts)-1]
+		if first {
+			dir, first = parts, false
+			continue
+		}
+		n := 0
+		for n < len(dir) && n < len(parts) && dir[n] == parts[n] {
+			n++
+		}
+		dir = dir[:n]
+	}
+	if len(dir) == 0 {
+		return paths, ""
+	}
+
+	prefix := strings.Join(dir, "/") + "/"
+	var trimmed []string
+	for _, p := range paths {
+		if rest, ok := strings.CutPrefix(p, prefix); ok {
+			trimmed = append(trimmed, rest)
+		}
+	}
+	return trimmed, prefix
+}
+
+// withSeparator rewrites a '/'-separated relative path to use sep
+func withSeparator(path, sep string) string {
+	if sep == "/" {
+		return path
+	}
+	return strings.ReplaceAll(path, "/", sep)
+}
+
+// walkNodes calls fn for n and each of its descendants in display order
+func walkNodes(n *Node, fn func(*Node)) {
+	fn(n)
+	for _, child := range n.Children {
+		walkNodes(child, fn)
+	}
+}
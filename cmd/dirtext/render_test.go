@@ -0,0 +1,576 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithSeparator(t *testing.T) {
+	tests := []struct {
+		path, sep, want string
+	}{
+		{"a.txt", `\`, `a.txt`},
+		{"cmd/dirtext/main.go", `\`, `cmd\dirtext\main.go`},
+		{"dir with space/file", `\`, `dir with space\file`},
+		{"cmd/dirtext/main.go", "/", "cmd/dirtext/main.go"},
+	}
+
+	for _, tt := range tests {
+		if got := withSeparator(tt.path, tt.sep); got != tt.want {
+			t.Errorf("withSeparator(%q, %q) = %q, want %q", tt.path, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestNativeSep(t *testing.T) {
+	root := makeTree(t, "a/b/c.txt", "d.txt")
+
+	native := render(t, "-flat", "-native-sep", root)
+	if want := lines("a", filepath.FromSlash("a/b"), filepath.FromSlash("a/b/c.txt"), "d.txt"); native != want {
+		t.Errorf("-native-sep:\ngot:\n%s\nwant:\n%s", native, want)
+	}
+
+	// -deterministic always writes '/'
+	pinned := render(t, "-flat", "-native-sep", "-deterministic", root)
+	if want := lines("a", "a/b", "a/b/c.txt", "d.txt"); pinned != want {
+		t.Errorf("-native-sep -deterministic:\ngot:\n%s\nwant:\n%s", pinned, want)
+	}
+}
+
+func TestCustomConnectors(t *testing.T) {
+	root := makeTree(t, "a", "dir/sub/b", "dir/c", "z")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, lines(
+			"root",
+			"├── a",
+			"├── dir",
+			"│   ├── c",
+			"│   └── sub",
+			"│       └── b",
+			"└── z",
+		)},
+		{[]string{"-mid-branch", "|-- ", "-last-branch", "`-- ", "-mid-guide", "|   ", "-empty-guide", "    "}, lines(
+			"root",
+			"|-- a",
+			"|-- dir",
+			"|   |-- c",
+			"|   `-- sub",
+			"|       `-- b",
+			"`-- z",
+		)},
+		{[]string{"-mid-branch", "+ ", "-last-branch", `\ `, "-mid-guide", "| ", "-empty-guide", "  "}, lines(
+			"root",
+			"+ a",
+			"+ dir",
+			"| + c",
+			`| \ sub`,
+			`|   \ b`,
+			`\ z`,
+		)},
+		// Each connector can be set on its own
+		{[]string{"-last-branch", "╰── "}, lines(
+			"root",
+			"├── a",
+			"├── dir",
+			"│   ├── c",
+			"│   ╰── sub",
+			"│       ╰── b",
+			"╰── z",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	for _, name := range []string{"mid-branch", "last-branch", "mid-guide", "empty-guide"} {
+		if _, _, err := runDirtext(t, "-"+name, "", root); err == nil {
+			t.Errorf("-%s '' was accepted", name)
+		}
+	}
+}
+
+func TestRootConnector(t *testing.T) {
+	root := makeTree(t, "a", "dir/b", "z")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-root-connector", "┌ "}, lines(
+			"┌ root",
+			"├── a",
+			"├── dir",
+			"│   └── b",
+			"└── z",
+		)},
+		// The last child still closes the tree when -max-per-dir hides the
+		// rest
+		{[]string{"-root-connector", "┌ ", "-max-per-dir", "2"}, lines(
+			"┌ root",
+			"├── a",
+			"├── dir",
+			"│   └── b",
+			"└── ... (1 more)",
+		)},
+		{[]string{"-root-connector", "* ", "-mid-branch", "|-- ", "-last-branch", "`-- "}, lines(
+			"* root",
+			"|-- a",
+			"|-- dir",
+			"│   `-- b",
+			"`-- z",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestPlainIndent(t *testing.T) {
+	root := makeTree(t, "a", "dir/b", "dir/sub/c", "empty/", "link -> dir")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-plain-indent"}, lines(
+			"root",
+			"  a",
+			"  dir",
+			"    b",
+			"    sub",
+			"      c",
+			"  empty",
+			"  link",
+		)},
+		{[]string{"-plain-indent", "-indent-width", "4", "-link-targets"}, lines(
+			"root",
+			"    a",
+			"    dir",
+			"        b",
+			"        sub",
+			"            c",
+			"    empty",
+			"    link -> dir",
+		)},
+		// Only directories below the root get the slash, and symlinks to
+		// them don't
+		{[]string{"-plain-indent", "-dir-slash"}, lines(
+			"root",
+			"  a",
+			"  dir/",
+			"    b",
+			"    sub/",
+			"      c",
+			"  empty/",
+			"  link",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-dir-slash"},
+		{"-plain-indent", "-indent-width", "0"},
+	} {
+		if _, _, err := runDirtext(t, append(args, root)...); err == nil {
+			t.Errorf("%v was accepted", args)
+		}
+	}
+}
+
+func TestBoxed(t *testing.T) {
+	root := makeTree(t, "dir/a")
+	writeFile(t, root, "conf", "k\tv\n")
+
+	// Previews have their tabs expanded and footers are framed too
+	want := lines(
+		"┌─ root ─────────────────────┐",
+		"│ ├── conf                   │",
+		"│ │     k    v               │",
+		"│ └── dir                    │",
+		"│     └── a                  │",
+		"│ 2 files, 1 dir, 0 symlinks │",
+		"└────────────────────────────┘",
+	)
+	if got := render(t, "-boxed", "-deterministic", "-count-by-type", "-preview", "1", root); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A title longer than the tree widens the box
+	long := makeTree(t, "a")
+	want = lines(
+		"┌─ root ─┐",
+		"│ └── a  │",
+		"└────────┘",
+	)
+	if got := render(t, "-boxed", "-deterministic", long); got != want {
+		t.Errorf("short tree:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Written anywhere but a terminal, the plain tree is printed
+	if got, want := render(t, "-boxed", long), lines("root", "└── a"); got != want {
+		t.Errorf("without a terminal:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// -o files are framed only with -deterministic
+	file := filepath.Join(t.TempDir(), "tree.txt")
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-boxed"}, lines("root", "└── a")},
+		{[]string{"-boxed", "-deterministic"}, want},
+	} {
+		render(t, append(tt.args, "-o", file, long)...)
+		if got, err := os.ReadFile(file); err != nil || string(got) != tt.want {
+			t.Errorf("%v -o:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	if _, _, err := runDirtext(t, "-boxed", "-flat", root); err == nil {
+		t.Error("-boxed -flat was accepted")
+	}
+}
+
+func TestSectionDepth(t *testing.T) {
+	root := makeTree(t, "a/f", "a/x/deep/g", "b/h", "empty/", "top")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// An empty directory's section is only its header
+		{[]string{"-section-depth", "1"}, lines(
+			"root",
+			"├── a",
+			"├── b",
+			"├── empty",
+			"└── top",
+			"",
+			"a",
+			"├── f",
+			"└── x",
+			"    └── deep",
+			"        └── g",
+			"",
+			"b",
+			"└── h",
+			"",
+			"empty",
+		)},
+		{[]string{"-section-depth", "2"}, lines(
+			"root",
+			"├── a",
+			"│   ├── f",
+			"│   └── x",
+			"├── b",
+			"│   └── h",
+			"├── empty",
+			"└── top",
+			"",
+			"a/x",
+			"└── deep",
+			"    └── g",
+		)},
+		{[]string{"-section-depth", "1", "-plain-indent"}, lines(
+			"root",
+			"  a",
+			"  b",
+			"  empty",
+			"  top",
+			"",
+			"a",
+			"  f",
+			"  x",
+			"    deep",
+			"      g",
+			"",
+			"b",
+			"  h",
+			"",
+			"empty",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-section-depth", "1", "-flat"},
+		{"-section-depth", "1", "-boxed"},
+	} {
+		if _, _, err := runDirtext(t, append(args, root)...); err == nil {
+			t.Errorf("%v was accepted", args)
+		}
+	}
+}
+
+func TestTrimCommonPrefix(t *testing.T) {
+	tests := []struct {
+		entries        []string
+		args           []string
+		stdout, stderr string
+	}{
+		{[]string{"a/b/c/x", "a/b/d/y"}, nil,
+			lines("c", "c/x", "d", "d/y"),
+			lines("Note: trimmed the common prefix a/b/")},
+		{[]string{"a/b/c/x", "a/b/c/z"}, nil,
+			lines("x", "z"),
+			lines("Note: trimmed the common prefix a/b/c/")},
+		// A single file comes down to its name
+		{[]string{"deep/er/file.go"}, nil,
+			lines("file.go"),
+			lines("Note: trimmed the common prefix deep/er/")},
+		// Nothing in common
+		{[]string{"a/x", "top"}, nil, lines("a", "a/x", "top"), ""},
+		{[]string{"a/x", "b/y"}, nil, lines("a", "a/x", "b", "b/y"), ""},
+		// A shared name prefix isn't a shared directory
+		{[]string{"ab/x", "abc/y"}, nil, lines("ab", "ab/x", "abc", "abc/y"), ""},
+		// Trimmed after filters
+		{[]string{"src/a/x.go", "src/a/y.go", "README.md"}, []string{"-ext", "go"},
+			lines("x.go", "y.go"),
+			lines("Note: trimmed the common prefix src/a/")},
+	}
+
+	for _, tt := range tests {
+		root := makeTree(t, tt.entries...)
+		args := append([]string{"-flat", "-trim-common-prefix"}, tt.args...)
+		stdout, stderr, err := runDirtext(t, append(args, root)...)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.entries, err)
+		}
+		if stdout != tt.stdout || stderr != tt.stderr {
+			t.Errorf("%v %v:\nstdout:\n%s\nwant:\n%s\nstderr %q, want %q", tt.entries, tt.args, stdout, tt.stdout, stderr, tt.stderr)
+		}
+	}
+
+	if _, _, err := runDirtext(t, "-trim-common-prefix", makeTree(t, "a")); err == nil {
+		t.Error("-trim-common-prefix without -flat was accepted")
+	}
+}
+
+func TestRTL(t *testing.T) {
+	root := makeTree(t, "a", "dir/b", "dir/sub/c", "ln -> a", "z")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-rtl"}, lines(
+			"         root",
+			"        a ──┤",
+			"      dir ──┤",
+			"    b ──┤   │",
+			"  sub ──┘   │",
+			"c ──┘       │",
+			"       ln ──┤",
+			"        z ──┘",
+		)},
+		// Custom connectors are mirrored too
+		{[]string{"-rtl", "-link-targets", "-last-branch", "╰── "}, lines(
+			"         root",
+			"        a ──┤",
+			"      dir ──┤",
+			"    b ──┤   │",
+			"  sub ──╯   │",
+			"c ──╯       │",
+			"  ln -> a ──┤",
+			"        z ──╯",
+		)},
+		{[]string{"-rtl", "-max-per-dir", "1"}, lines(
+			"            root",
+			"           a ──┤",
+			"... (3 more) ──┘",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	// Wide characters take two columns of the margin
+	wide := makeTree(t, "dir/b", "日本.txt")
+	want := lines(
+		"        root",
+		"     dir ──┤",
+		"   b ──┘   │",
+		"日本.txt ──┘",
+	)
+	if got := render(t, "-rtl", wide); got != want {
+		t.Errorf("wide names:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, args := range [][]string{
+		{"-rtl", "-flat"},
+		{"-rtl", "-plain-indent"},
+	} {
+		if _, _, err := runDirtext(t, append(args, root)...); err == nil {
+			t.Errorf("%v was accepted", args)
+		}
+	}
+}
+
+func TestAlignColumns(t *testing.T) {
+	root := makeTree(t, "a.txt", "l2 -> sub/x", "sub/link -> ../a.txt", "sub/node_modules/", "sub/x")
+	writeFile(t, root, "sub/p.txt", "l1\nl2\n")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-hash", "-show-depth", "-link-targets"}, lines(
+			"root",
+			"├── a.txt                          (sha256:e3b0c44298fc)  (depth 1)",
+			"├── l2                -> sub/x                            (depth 1)",
+			"└── sub                                                   (depth 1)",
+			"    ├── link          -> ../a.txt                         (depth 2)",
+			"    ├── node_modules                                      (depth 2)  [...]",
+			"    ├── p.txt                      (sha256:259ed5007f3f)  (depth 2)",
+			"    └── x                          (sha256:e3b0c44298fc)  (depth 2)",
+		)},
+		// A line's last cell starts no column, and a preview line ends the
+		// block above it
+		{[]string{"-link-targets", "-preview", "1"}, lines(
+			"root",
+			"├── a.txt",
+			"├── l2  -> sub/x",
+			"└── sub",
+			"    ├── link          -> ../a.txt",
+			"    ├── node_modules  [...]",
+			"    ├── p.txt",
+			"    │     l1",
+			"    └── x",
+		)},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-align-columns"}, tt.args...)
+		if got := render(t, append(args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	// Wide characters are measured in terminal columns
+	wide := makeTree(t, "日本 -> x", "ab -> y", "x", "y")
+	want := lines(
+		"root",
+		"├── ab    -> y  (depth 1)",
+		"├── x           (depth 1)",
+		"├── y           (depth 1)",
+		"└── 日本  -> x  (depth 1)",
+	)
+	if got := render(t, "-align-columns", "-link-targets", "-show-depth", wide); got != want {
+		t.Errorf("wide names:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, args := range [][]string{
+		{"-align-columns", "-flat"},
+		{"-align-columns", "-plain-indent"},
+		{"-align-columns", "-rtl"},
+	} {
+		if _, _, err := runDirtext(t, append(args, root)...); err == nil {
+			t.Errorf("%v was accepted", args)
+		}
+	}
+}
+
+func TestFlattenBelow(t *testing.T) {
+	root := makeTree(t, "a/b/c/d/four", "a/b/c/three", "a/b/empty/", "a/b/two", "a/e/five", "a/one", "top", "x/six", "y/node_modules/p/i")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// Leaf and empty directories are listed, deeper ones only through
+		// what they hold
+		{[]string{"-flatten-below", "1"}, lines(
+			"root",
+			"├── a",
+			"│   ├── b/c/d/four",
+			"│   ├── b/c/three",
+			"│   ├── b/empty",
+			"│   ├── b/two",
+			"│   ├── e/five",
+			"│   └── one",
+			"├── top",
+			"├── x",
+			"│   └── six",
+			"└── y",
+			"    └── node_modules [...]",
+		)},
+		{[]string{"-flatten-below", "2"}, lines(
+			"root",
+			"├── a",
+			"│   ├── b",
+			"│   │   ├── c/d/four",
+			"│   │   ├── c/three",
+			"│   │   ├── empty",
+			"│   │   └── two",
+			"│   ├── e",
+			"│   │   └── five",
+			"│   └── one",
+			"├── top",
+			"├── x",
+			"│   └── six",
+			"└── y",
+			"    └── node_modules [...]",
+		)},
+		// -max-per-dir counts the listed entries
+		{[]string{"-flatten-below", "1", "-max-per-dir", "2"}, lines(
+			"root",
+			"├── a",
+			"│   ├── b/c/d/four",
+			"│   ├── b/c/three",
+			"│   └── ... (4 more)",
+			"├── top",
+			"└── ... (2 more)",
+		)},
+		{[]string{"-flatten-below", "2", "-plain-indent", "-dir-slash"}, lines(
+			"root",
+			"  a/",
+			"    b/",
+			"      c/d/four",
+			"      c/three",
+			"      empty/",
+			"      two",
+			"    e/",
+			"      five",
+			"    one",
+			"  top",
+			"  x/",
+			"    six",
+			"  y/",
+			"    node_modules/ [...]",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	if _, _, err := runDirtext(t, "-flatten-below", "1", "-flat", root); err == nil {
+		t.Error("-flatten-below -flat was accepted")
+	}
+}
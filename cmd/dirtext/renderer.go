@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+// Renderer renders trees with one set of options, sharing parsed .gitignore
+// files between the roots it renders. It holds no other state, and the only
+// package-level variable dirtext ever assigns is the openedFile hook, which
+// only tests set and never while rendering concurrently, so one Renderer can
+// render different roots from several goroutines at once.
+type Renderer struct {
+	opts        *Options
+	ignoreFiles *gitignoreFiles
+}
+
+// newRenderer creates a Renderer for validated options, which must not be
+// changed while it is in use
+func newRenderer(opts *Options) *Renderer {
+	return &Renderer{opts: opts, ignoreFiles: newGitignoreFiles()}
+}
+
+// Render scans rootDir and writes its tree to w, with warnings going to
+// stderr. It returns what the scan did, including any errors skipped with
+// -errors-json.
+func (r *Renderer) Render(w io.Writer, rootDir string, stderr io.Writer) (scanReport, error) {
+	var out bytes.Buffer
+	report := scanReport{}
+	if err := renderRoot(&out, rootDir, r.opts, stderr, r.ignoreFiles, &report); err != nil {
+		return report, err
+	}
+
+	_, err := w.Write(out.Bytes())
+	return report, err
+}
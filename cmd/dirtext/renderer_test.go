@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// TestRendererConcurrent renders several roots, each of them more than once,
+// from one Renderer at the same time. Run it with -race to check that the
+// shared .gitignore cache is the only state the renders share.
+func TestRendererConcurrent(t *testing.T) {
+	var roots []string
+	for i := range 4 {
+		root := makeTree(t, "a.go", "b.log", "dir/c.go", "dir/sub/d.txt", fmt.Sprintf("only%d", i))
+		writeFile(t, root, ".gitignore", "*.log\n")
+		writeFile(t, root, "dir/.gitignore", "*.txt\n")
+		writeFile(t, root, "a.go", "package a\n")
+		roots = append(roots, root)
+	}
+
+	opts, err := parseFlags([]string{"-hash", "-sort", "size", "-count-by-type"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRenderer(opts)
+
+	// What each root renders to on its own
+	want := make([]string, len(roots))
+	for i, root := range roots {
+		var out bytes.Buffer
+		if _, err := newRenderer(opts).Render(&out, root, io.Discard); err != nil {
+			t.Fatal(err)
+		}
+		want[i] = out.String()
+	}
+
+	const rounds = 8
+	got := make([]string, len(roots)*rounds)
+	errs := make([]error, len(got))
+	var wg sync.WaitGroup
+	for i := range got {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out bytes.Buffer
+			_, errs[i] = r.Render(&out, roots[i%len(roots)], io.Discard)
+			got[i] = out.String()
+		}()
+	}
+	wg.Wait()
+
+	for i := range got {
+		if errs[i] != nil {
+			t.Errorf("render %d: %v", i, errs[i])
+		} else if got[i] != want[i%len(roots)] {
+			t.Errorf("render %d:\ngot:\n%s\nwant:\n%s", i, got[i], want[i%len(roots)])
+		}
+	}
+}
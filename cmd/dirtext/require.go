@@ -0,0 +1,26 @@
+package main
+
+// missingRequirements returns the -require patterns that no entry of the tree
+// matches. Patterns are matched like -include ones, against directories as
+// well as files, and a pattern with braces is met by any of its expansions.
+func missingRequirements(root *Node, patterns []string) []string {
+	met := make([]bool, len(patterns))
+	walkNodes(root, func(n *Node) {
+		if n == root || n.Filtered {
+			return
+		}
+		for i, pattern := range patterns {
+			if !met[i] && matchesAny(n.Path, n.IsDir, expandBraces(pattern)) {
+				met[i] = true
+			}
+		}
+	})
+
+	var missing []string
+	for i, pattern := range patterns {
+		if !met[i] {
+			missing = append(missing, pattern)
+		}
+	}
+	return missing
+}
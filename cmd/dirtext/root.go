@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// findRepoRoot walks up from dir until it finds a directory containing .git
+func findRepoRoot(dir string) (string, error) {
+	// .git is a directory in a normal clone and a file in worktrees and
+	// submodules, so either counts
+	root, err := findEnclosing(dir, ".git")
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("no git repository found in %s or any parent directory", dir)
+	}
+	return root, err
+}
+
+// findGoModuleRoot walks up from dir until it finds a directory containing
+// go.mod
+func findGoModuleRoot(dir string) (string, error) {
+	root, err := findEnclosing(dir, "go.mod")
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("no go.mod found in %s or any parent directory", dir)
+	}
+	return root, err
+}
+
+// findEnclosing returns dir or its nearest parent that contains an entry
+// called name, or an error wrapping os.ErrNotExist if none does
+func findEnclosing(dir, name string) (string, error) {
+	current := dir
+	for {
+		_, err := os.Stat(filepath.Join(current, name))
+		if err == nil {
+			return current, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", err
+		}
+		current = parent
+	}
+}
+
+// goModulePath returns the module path declared by the go.mod in dir
+func goModulePath(dir string) (string, error) {
+	file, err := openReadOnly(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "module" {
+			continue
+		}
+
+		// The path may be quoted
+		if unquoted, err := strconv.Unquote(fields[1]); err == nil {
+			return unquoted, nil
+		}
+		return fields[1], nil
+	}
+
+	return "", fmt.Errorf("%s has no module line", filepath.Join(dir, "go.mod"))
+}
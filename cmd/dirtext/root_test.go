@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGoModulePath(t *testing.T) {
+	tests := []struct {
+		gomod, want string
+	}{
+		{"module github.com/deelawn/dirtext\n\ngo 1.23.5\n", "github.com/deelawn/dirtext"},
+		{"// a comment\nmodule example.com/m // trailing\n", "example.com/m"},
+		{"module \"example.com/quoted\"\n", "example.com/quoted"},
+		{"go 1.22\n\nmodule   example.com/spaced\t\n", "example.com/spaced"},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		writeFile(t, dir, "go.mod", tt.gomod)
+		if got, err := goModulePath(dir); err != nil || got != tt.want {
+			t.Errorf("goModulePath(%q) = %q, %v, want %q", tt.gomod, got, err, tt.want)
+		}
+	}
+
+	dir := t.TempDir()
+	writeFile(t, dir, "go.mod", "go 1.23\n")
+	if _, err := goModulePath(dir); err == nil {
+		t.Error("a go.mod without a module line was accepted")
+	}
+}
+
+func TestGoModuleRoot(t *testing.T) {
+	root := makeTree(t, "cmd/tool/main.go", "go.mod")
+	writeFile(t, root, "go.mod", "module example.com/tool\n")
+
+	// Scanned from below the module root, named after the module
+	want := lines(
+		"example.com/tool",
+		"├── cmd",
+		"│   └── tool",
+		"│       └── main.go",
+		"└── go.mod",
+	)
+	if got := render(t, "-go-module-root", filepath.Join(root, "cmd", "tool")); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// renderSitemap writes the visible files as a sitemap.xml urlset. Each file's
+// location is its relative path, percent-encoded and prefixed with baseURL if
+// set. With lastmod each entry also carries the file's mtime.
+func renderSitemap(w io.Writer, root *Node, baseURL string, lastmod bool) {
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+
+	walkNodes(root, func(n *Node) {
+		if n == root || n.IsDir {
+			return
+		}
+
+		fmt.Fprintln(w, "\t<url>")
+		fmt.Fprintf(w, "\t\t<loc>%s</loc>\n", xmlEscape(sitemapLoc(baseURL, n.Path)))
+		if lastmod && !n.ModTime.IsZero() {
+			fmt.Fprintf(w, "\t\t<lastmod>%s</lastmod>\n", n.ModTime.UTC().Format(time.RFC3339))
+		}
+		fmt.Fprintln(w, "\t</url>")
+	})
+
+	fmt.Fprintln(w, `</urlset>`)
+}
+
+// sitemapLoc joins baseURL and the '/'-separated path, escaping each element
+// of the path
+func sitemapLoc(baseURL, path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	loc := strings.Join(parts, "/")
+
+	if baseURL == "" {
+		return loc
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + loc
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSitemap(t *testing.T) {
+	root := makeTree(t, "about us.html", "a&b<c>.html", "docs/日本.html", "empty/")
+	stamp := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(root, "about us.html"), stamp, stamp); err != nil {
+		t.Fatal(err)
+	}
+
+	type url struct {
+		Loc     string `xml:"loc"`
+		Lastmod string `xml:"lastmod"`
+	}
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+		URLs    []url    `xml:"url"`
+	}
+	out := render(t, "-format", "sitemap", "-base-url", "https://example.com/", "-sitemap-lastmod", root)
+	if err := xml.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out)
+	}
+
+	// Files only, percent-encoded, and escaped for XML
+	var locs []string
+	for _, u := range doc.URLs {
+		locs = append(locs, u.Loc)
+	}
+	want := []string{
+		"https://example.com/a&b%3Cc%3E.html",
+		"https://example.com/about%20us.html",
+		"https://example.com/docs/%E6%97%A5%E6%9C%AC.html",
+	}
+	if !reflect.DeepEqual(locs, want) {
+		t.Errorf("locs %q, want %q", locs, want)
+	}
+	if len(doc.URLs) == 3 && doc.URLs[1].Lastmod != "2020-01-02T03:04:05Z" {
+		t.Errorf("lastmod %q", doc.URLs[1].Lastmod)
+	}
+
+	for _, args := range [][]string{
+		{"-sitemap-lastmod"},
+		{"-format", "sitemap", "-sitemap-lastmod", "-deterministic"},
+	} {
+		if _, _, err := runDirtext(t, append(args, root)...); err == nil {
+			t.Errorf("%v was accepted", args)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+)
+
+// renderSlog writes one structured log record per visible entry as JSON
+// lines, through a log/slog JSON handler. Without timestamps the records are
+// byte-identical across runs.
+func renderSlog(w io.Writer, root *Node, timestamps bool) {
+	logger := slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if !timestamps && len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	logger = logger.With("root", root.Name)
+
+	walkNodes(root, func(n *Node) {
+		if n != root && !n.Filtered {
+			logger.Info("entry", "path", n.Path, "depth", n.Depth(), "isDir", n.IsDir)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+)
+
+// computeTotals fills in the recursive file count and size of every
+// directory in the tree
+func computeTotals(n *Node) {
+	if !n.IsDir {
+		n.TotalFiles, n.TotalSize = 1, n.Size
+		return
+	}
+
+	n.TotalFiles, n.TotalSize = 0, 0
+	for _, child := range n.Children {
+		computeTotals(child)
+		n.TotalFiles += child.TotalFiles
+		n.TotalSize += child.TotalSize
+	}
+}
+
+// typeCounts is a census of the visible entries of a tree by type
+type typeCounts struct {
+	files, dirs, symlinks, others int
+}
+
+// countTypes counts the visible entries beneath root by type. Sockets,
+// devices and named pipes count as others.
+func countTypes(root *Node) typeCounts {
+	var c typeCounts
+	walkNodes(root, func(n *Node) {
+		if n == root || n.Filtered {
+			return
+		}
+
+		switch {
+		case n.IsDir:
+			c.dirs++
+		case n.Mode&fs.ModeSymlink != 0:
+			c.symlinks++
+		case n.Mode.IsRegular():
+			c.files++
+		default:
+			c.others++
+		}
+	})
+	return c
+}
+
+// String formats the counts as a summary line, such as
+// "42 files, 8 dirs, 3 symlinks". Others are only mentioned if there are any.
+func (c typeCounts) String() string {
+	line := fmt.Sprintf("%s, %s, %s", plural(c.files, "file"), plural(c.dirs, "dir"), plural(c.symlinks, "symlink"))
+	if c.others > 0 {
+		line += ", " + plural(c.others, "other")
+	}
+	return line
+}
+
+// maxDepth returns the depth of the most deeply nested visible entry beneath
+// root, counted from the scan root
+func maxDepth(root *Node) int {
+	deepest := 0
+	walkNodes(root, func(n *Node) {
+		if n != root && !n.Filtered && n.Depth() > deepest {
+			deepest = n.Depth()
+		}
+	})
+	return deepest
+}
+
+// plural formats a count of things, adding an s unless there is one
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// renderDirSummary writes one line per directory with its immediate child
+// count, recursive file count and total size, ordered by path or, with
+// bySize, largest first
+func renderDirSummary(w io.Writer, root *Node, bySize bool) error {
+	computeTotals(root)
+
+	var dirs []*Node
+	walkNodes(root, func(n *Node) {
+		if n.IsDir {
+			dirs = append(dirs, n)
+		}
+	})
+
+	if bySize {
+		sort.SliceStable(dirs, func(i, j int) bool {
+			return dirs[i].TotalSize > dirs[j].TotalSize
+		})
+	}
+
+	rows := [][]string{{"PATH", "ENTRIES", "FILES", "SIZE"}}
+	for _, dir := range dirs {
+		path := dir.Path
+		if path == "" {
+			path = "."
+		}
+		rows = append(rows, []string{path,
+			strconv.Itoa(len(visibleChildren(dir))), strconv.Itoa(dir.TotalFiles), formatSize(dir.TotalSize)})
+	}
+
+	return writeTable(w, rows)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the helpers available to -template-file templates
+var templateFuncs = template.FuncMap{
+	// indent prefixes every line of s with n spaces
+	"indent": func(n int, s string) string {
+		pad := strings.Repeat(" ", n)
+		return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+	},
+	// repeat returns s repeated n times
+	"repeat": func(n int, s string) string {
+		return strings.Repeat(s, max(n, 0))
+	},
+	// base returns the last element of a '/'-separated path
+	"base": path.Base,
+	// children returns the printed children of a node, skipping over
+	// directories hidden by -type-filter
+	"children": visibleChildren,
+}
+
+// renderTemplateFile executes the text/template in templatePath with the root
+// Node of the tree as its data
+func renderTemplateFile(w io.Writer, root *Node, templatePath string) error {
+	file, err := openReadOnly(templatePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	text, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New(path.Base(templatePath)).Funcs(templateFuncs).Parse(string(text))
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, root)
+}
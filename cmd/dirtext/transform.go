@@ -0,0 +1,441 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// limitChildren keeps at most limit children in every directory, recording
+// how many were dropped so renderers can say so. Children are kept in their
+// sorted order. If rng isn't nil it picks which children to keep, otherwise
+// the first ones are kept.
+func limitChildren(n *Node, limit int, rng *rand.Rand) {
+	if len(n.Children) > limit {
+		n.More = len(n.Children) - limit
+		if rng == nil {
+			n.Children = n.Children[:limit]
+		} else {
+			picked := rng.Perm(len(n.Children))[:limit]
+			sort.Ints(picked)
+
+			kept := make([]*Node, 0, limit)
+			for _, i := range picked {
+				kept = append(kept, n.Children[i])
+			}
+			n.Children = kept
+		}
+	}
+
+	for _, child := range n.Children {
+		limitChildren(child, limit, rng)
+	}
+}
+
+// groupChildren reorders every directory's children so directories come
+// before files (dirsFirst) or after them, keeping the name order within each
+// group
+func groupChildren(n *Node, dirsFirst bool) {
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		a, b := n.Children[i].IsDir, n.Children[j].IsDir
+		if dirsFirst {
+			return a && !b
+		}
+		return !a && b
+	})
+
+	for _, child := range n.Children {
+		groupChildren(child, dirsFirst)
+	}
+}
+
+// treeSortLess is the -tree-sort comparator: directories before files, then
+// names compared case-insensitively, with byte order breaking ties so the
+// order stays deterministic
+func treeSortLess(a, b *Node) bool {
+	if a.IsDir != b.IsDir {
+		return a.IsDir
+	}
+
+	if fa, fb := strings.ToLower(a.Name), strings.ToLower(b.Name); fa != fb {
+		return fa < fb
+	}
+	return a.Name < b.Name
+}
+
+// loadOrderFile reads an -order-file, returning each listed path's position.
+// A directory holding listed paths takes the position of the first of them,
+// so it moves up along with its contents. Blank lines and lines starting with
+// '#' are skipped.
+func loadOrderFile(name string) (map[string]int, error) {
+	file, err := openReadOnly(name)
+	if err != nil {
+		return nil, fmt.Errorf("-order-file: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("-order-file: %w", err)
+	}
+
+	order := make(map[string]int)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p := strings.Trim(path.Clean(filepath.ToSlash(line)), "/")
+		for ; p != "." && p != ""; p = path.Dir(p) {
+			if _, ok := order[p]; !ok {
+				order[p] = i
+			}
+		}
+	}
+	return order, nil
+}
+
+// orderLess returns the -order-file comparator: listed entries in the order
+// they are listed, before unlisted ones, which keep their existing order
+func orderLess(order map[string]int) func(a, b *Node) bool {
+	return func(a, b *Node) bool {
+		ia, listedA := order[a.Path]
+		ib, listedB := order[b.Path]
+		if listedA != listedB {
+			return listedA
+		}
+		return listedA && ia < ib
+	}
+}
+
+// sortChildren reorders every directory's children with less
+func sortChildren(n *Node, less func(a, b *Node) bool) {
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		return less(n.Children[i], n.Children[j])
+	})
+
+	for _, child := range n.Children {
+		sortChildren(child, less)
+	}
+}
+
+// sortBySize reorders every directory's children largest first, counting a
+// directory's size as the total of everything beneath it. Entries of equal
+// size keep their name order.
+func sortBySize(root *Node) {
+	computeTotals(root)
+	sortBySizeTotals(root)
+}
+
+func sortBySizeTotals(n *Node) {
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		return n.Children[i].TotalSize > n.Children[j].TotalSize
+	})
+
+	for _, child := range n.Children {
+		sortBySizeTotals(child)
+	}
+}
+
+// applySizeBudget drops the entries that follow, in display order, the file
+// that pushes the total size of the files shown past budget. Directories
+// record how many of their children were dropped, like -max-per-dir, and the
+// number of entries dropped in all is returned.
+func applySizeBudget(root *Node, budget int64) int {
+	var used int64
+	var exceeded bool
+	var omitted int
+
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		kept := n.Children[:0]
+		for _, child := range n.Children {
+			if !exceeded && !child.IsDir && used+child.Size > budget {
+				exceeded = true
+			}
+			if exceeded {
+				n.More++
+				omitted += countNodes(child)
+				continue
+			}
+
+			if !child.IsDir {
+				used += child.Size
+			}
+			kept = append(kept, child)
+			visit(child)
+		}
+		n.Children = kept
+	}
+	visit(root)
+
+	return omitted
+}
+
+// applyNodeBudget keeps the budget shallowest entries, breadth first: every
+// entry at one depth is kept before any deeper one, and entries at the same
+// depth are taken in display order. A kept directory that lost all its
+// children is marked collapsed, and one that lost only some records how many,
+// like -max-per-dir. The number of entries dropped in all is returned.
+func applyNodeBudget(root *Node, budget int) int {
+	kept := map[*Node]bool{root: true}
+	used := 0
+
+	// Directories hidden by -type-filter cost nothing; they are only passed
+	// through, and dropped later if nothing beneath them is kept
+	queue := []*Node{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, child := range n.Children {
+			if !child.Filtered {
+				if used == budget {
+					continue
+				}
+				used++
+			}
+			kept[child] = true
+			queue = append(queue, child)
+		}
+	}
+
+	omitted := 0
+	var prune func(n *Node) int
+	prune = func(n *Node) int {
+		dropped := 0
+		children := n.Children[:0]
+		for _, child := range n.Children {
+			if !kept[child] {
+				dropped++
+				omitted += countNodes(child)
+				continue
+			}
+
+			// A hidden directory left empty takes its losses to its parent
+			if lost := prune(child); lost > 0 && child.Filtered && len(child.Children) == 0 {
+				dropped += lost
+				continue
+			}
+			children = append(children, child)
+		}
+		n.Children = children
+
+		switch {
+		case dropped == 0 || n.Filtered && len(n.Children) == 0:
+		case len(n.Children) == 0:
+			n.Collapsed = true
+			n.More = 0
+		default:
+			n.More += dropped
+		}
+		return dropped
+	}
+	prune(root)
+
+	return omitted
+}
+
+// countNodes returns the number of visible entries in n's subtree, n included
+func countNodes(n *Node) int {
+	count := 0
+	walkNodes(n, func(n *Node) {
+		if !n.Filtered {
+			count++
+		}
+	})
+	return count
+}
+
+// byteSize is a flag holding a size in bytes, given as a plain number or
+// with a K, M, G or T suffix (powers of 1024, with an optional "iB" or "B")
+type byteSize int64
+
+func (s *byteSize) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *byteSize) Set(value string) error {
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+	*s = byteSize(size)
+	return nil
+}
+
+// parseSize parses a size such as "512", "64K", "1.5MiB" or "2GB"
+func parseSize(value string) (int64, error) {
+	number := strings.TrimSpace(value)
+	number = strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(number), "B"), "I")
+
+	multiplier := int64(1)
+	if i := strings.IndexAny(number, "KMGT"); i >= 0 && i == len(number)-1 {
+		exp := strings.IndexByte("KMGT", number[i]) + 1
+		for ; exp > 0; exp-- {
+			multiplier *= 1024
+		}
+		number = number[:i]
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (want e.g. 512, 64K or 1.5M)", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// keepDeepest reduces the tree to the paths leading to its most deeply
+// nested files, noting their depth. Every file tied for deepest is kept.
+func keepDeepest(root *Node) {
+	deepest := 0
+	walkNodes(root, func(n *Node) {
+		if !n.IsDir && n.Depth() > deepest {
+			deepest = n.Depth()
+		}
+	})
+
+	keepAtDepth(root, deepest)
+}
+
+// keepAtDepth drops every child of n that isn't a file at depth or a
+// directory leading to one, reporting whether any were kept
+func keepAtDepth(n *Node, depth int) bool {
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		switch {
+		case child.IsDir && !keepAtDepth(child, depth):
+			continue
+		case !child.IsDir && child.Depth() != depth:
+			continue
+		case !child.IsDir:
+			child.Notes = append(child.Notes, fmt.Sprintf("depth %d", depth))
+		}
+		children = append(children, child)
+	}
+	n.Children = children
+	return len(children) > 0
+}
+
+// liftFiltered replaces the directories hidden by -type-filter beneath n with
+// the entries they hold, which a text tree has no line to hang from
+// otherwise. The lifted entries keep their place in display order and are
+// named by their path from n; hidden directories holding nothing disappear.
+func liftFiltered(n *Node) {
+	var children []*Node
+	var collect func(dir *Node)
+	collect = func(dir *Node) {
+		for _, child := range dir.Children {
+			if child.Filtered {
+				collect(child)
+				continue
+			}
+
+			if dir != n {
+				child.Name = strings.TrimPrefix(child.Path, n.Path+"/")
+			}
+			liftFiltered(child)
+			children = append(children, child)
+		}
+	}
+	collect(n)
+	n.Children = children
+}
+
+// focusTree returns the subtree of the directory at the '/'-separated path
+// focus, relative to the root, named by that path. Its nodes keep their paths
+// relative to the scan root.
+func focusTree(root *Node, focus string) (*Node, error) {
+	focus = strings.Trim(path.Clean("/"+filepath.ToSlash(focus)), "/")
+
+	var found *Node
+	walkNodes(root, func(n *Node) {
+		if n.Path == focus && n.IsDir {
+			found = n
+		}
+	})
+	if found == nil {
+		return nil, fmt.Errorf("-focus %s: no such directory in the tree", focus)
+	}
+
+	focused := *found
+	if focus != "" {
+		focused.Name = focus
+	}
+	focused.Filtered = false
+	return &focused, nil
+}
+
+// noteDepths notes the depth of every entry beneath root, counted from the
+// scan root even when root is a -focus subtree
+func noteDepths(root *Node) {
+	walkNodes(root, func(n *Node) {
+		if n != root {
+			n.Notes = append(n.Notes, fmt.Sprintf("depth %d", n.Depth()))
+		}
+	})
+}
+
+// splitSections cuts the tree at the directories depth levels below root,
+// returning the top of the tree, in which those directories are shown
+// without their contents, and the directories themselves in display order,
+// each named by its path so it can head its own section
+func splitSections(root *Node, depth int) (*Node, []*Node) {
+	var sections []*Node
+
+	var cut func(n *Node, level int) *Node
+	cut = func(n *Node, level int) *Node {
+		copied := *n
+		if n.IsDir && level == depth {
+			section := *n
+			section.Name = n.Path
+			sections = append(sections, &section)
+
+			copied.Children, copied.More = nil, 0
+			return &copied
+		}
+
+		copied.Children = nil
+		for _, child := range n.Children {
+			copied.Children = append(copied.Children, cut(child, level+1))
+		}
+		return &copied
+	}
+
+	top := cut(root, 0)
+	return top, sections
+}
+
+// flattenBelow stops nesting directories more than depth levels below root.
+// Each directory at that depth lists everything beneath it directly, with
+// subdirectories replaced by the files, leaf directories and empty
+// directories they hold, named by their path from the listing directory.
+func flattenBelow(n *Node, depth int) {
+	if n.Depth() < depth {
+		for _, child := range n.Children {
+			flattenBelow(child, depth)
+		}
+		return
+	}
+
+	var flattened []*Node
+	var collect func(dir *Node)
+	collect = func(dir *Node) {
+		for _, child := range dir.Children {
+			if child.IsDir && (len(child.Children) > 0 || child.Filtered) {
+				collect(child)
+				continue
+			}
+
+			child.Name = strings.TrimPrefix(child.Path, n.Path+"/")
+			flattened = append(flattened, child)
+		}
+	}
+	collect(n)
+	n.Children = flattened
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMaxPerDir(t *testing.T) {
+	root := makeTree(t, "a", "b", "c", "big/1", "big/2", "big/3", "big/4", "big/5", "small/x")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-max-per-dir", "2"}, lines(
+			"root",
+			"├── a",
+			"├── b",
+			"└── ... (3 more)",
+		)},
+		{[]string{"-max-per-dir", "4"}, lines(
+			"root",
+			"├── a",
+			"├── b",
+			"├── big",
+			"│   ├── 1",
+			"│   ├── 2",
+			"│   ├── 3",
+			"│   ├── 4",
+			"│   └── ... (1 more)",
+			"├── c",
+			"└── ... (1 more)",
+		)},
+		{[]string{"-max-per-dir", "5"}, lines(
+			"root",
+			"├── a",
+			"├── b",
+			"├── big",
+			"│   ├── 1",
+			"│   ├── 2",
+			"│   ├── 3",
+			"│   ├── 4",
+			"│   └── 5",
+			"├── c",
+			"└── small",
+			"    └── x",
+		)},
+		{[]string{"-max-per-dir", "3", "-plain-indent"}, lines(
+			"root",
+			"  a",
+			"  b",
+			"  big",
+			"    1",
+			"    2",
+			"    3",
+			"    ... (2 more)",
+			"  ... (2 more)",
+		)},
+		// Children are chosen after sorting
+		{[]string{"-max-per-dir", "2", "-dirs-first"}, lines(
+			"root",
+			"├── big",
+			"│   ├── 1",
+			"│   ├── 2",
+			"│   └── ... (3 more)",
+			"├── small",
+			"│   └── x",
+			"└── ... (3 more)",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestFilesFirst(t *testing.T) {
+	root := makeTree(t, "b.txt", "a/", "c/z", "c/y/", "d.txt", "C.txt")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-files-first"}, lines(
+			"root",
+			"├── C.txt",
+			"├── b.txt",
+			"├── d.txt",
+			"├── a",
+			"└── c",
+			"    ├── z",
+			"    └── y",
+		)},
+		{[]string{"-dirs-first"}, lines(
+			"root",
+			"├── a",
+			"├── c",
+			"│   ├── y",
+			"│   └── z",
+			"├── C.txt",
+			"├── b.txt",
+			"└── d.txt",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	if _, _, err := runDirtext(t, "-files-first", "-dirs-first", root); err == nil {
+		t.Error("-files-first -dirs-first was accepted")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"512", 512},
+		{"64K", 64 << 10},
+		{"64k", 64 << 10},
+		{"64KiB", 64 << 10},
+		{"1.5M", 3 << 19},
+		{"2G", 2 << 30},
+		{"1T", 1 << 40},
+		{"0", 0},
+	}
+
+	for _, tt := range tests {
+		if got, err := parseSize(tt.value); err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "1.5X", "-1", "K", "1KM"} {
+		if _, err := parseSize(value); err == nil {
+			t.Errorf("parseSize(%q) was accepted", value)
+		}
+	}
+}
+
+func TestSizeBudget(t *testing.T) {
+	root := makeTree(t, "dir/")
+	writeFile(t, root, "a", strings.Repeat("a", 600))
+	writeFile(t, root, "dir/b", strings.Repeat("b", 300))
+	writeFile(t, root, "dir/c", strings.Repeat("c", 300))
+	writeFile(t, root, "z", strings.Repeat("z", 10))
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// "dir/c" is the first file over the budget, so it and "z" are left
+		// out
+		{[]string{"-size-budget", "1000"}, lines(
+			"root",
+			"├── a",
+			"├── dir",
+			"│   ├── b",
+			"│   └── ... (1 more)",
+			"└── ... (1 more)",
+			"(size budget of 1000 B reached, 2 more entries not shown)",
+		)},
+		// A file that exactly fills the budget is still shown
+		{[]string{"-size-budget", "900"}, lines(
+			"root",
+			"├── a",
+			"├── dir",
+			"│   ├── b",
+			"│   └── ... (1 more)",
+			"└── ... (1 more)",
+			"(size budget of 900 B reached, 2 more entries not shown)",
+		)},
+		// The budget is spent in display order
+		{[]string{"-size-budget", "1K", "-files-first"}, lines(
+			"root",
+			"├── a",
+			"├── z",
+			"└── dir",
+			"    ├── b",
+			"    └── ... (1 more)",
+			"(size budget of 1.0 KiB reached, 1 more entries not shown)",
+		)},
+		{[]string{"-size-budget", "1210"}, lines(
+			"root",
+			"├── a",
+			"├── dir",
+			"│   ├── b",
+			"│   └── c",
+			"└── z",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	// Structured output only holds what fits, with the note on stderr
+	stdout, stderr, err := runDirtext(t, "-size-budget", "1000", "-format", "paths-json", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(size budget of 1000 B reached, 2 more entries not shown)\n"; stderr != want {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+	if strings.Contains(stdout, "dir/c") || strings.Contains(stdout, `"z"`) {
+		t.Errorf("paths over the budget were written:\n%s", stdout)
+	}
+}
+
+func TestNodeBudget(t *testing.T) {
+	root := makeTree(t, "a/a1/x", "b/b1/y", "b/b2/z", "c/c1/w", "top")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// A directory that lost all its children is collapsed, and one that
+		// lost some ends with a marker
+		{[]string{"-node-budget", "6"}, lines(
+			"root",
+			"├── a",
+			"│   └── a1 [...]",
+			"├── b",
+			"│   ├── b1 [...]",
+			"│   └── ... (1 more)",
+			"├── c [...]",
+			"└── top",
+			"(node budget of 6 reached, 6 more entries not shown)",
+		)},
+		// Every entry one level down is kept before any deeper one
+		{[]string{"-node-budget", "3"}, lines(
+			"root",
+			"├── a [...]",
+			"├── b [...]",
+			"├── c [...]",
+			"└── ... (1 more)",
+			"(node budget of 3 reached, 9 more entries not shown)",
+		)},
+		{[]string{"-node-budget", "5", "-files-first"}, lines(
+			"root",
+			"├── top",
+			"├── a",
+			"│   └── a1 [...]",
+			"├── b [...]",
+			"└── c [...]",
+			"(node budget of 5 reached, 7 more entries not shown)",
+		)},
+		// Markers from -max-per-dir add up with the budget's
+		{[]string{"-node-budget", "2", "-max-per-dir", "1"}, lines(
+			"root",
+			"├── a",
+			"│   └── a1 [...]",
+			"└── ... (3 more)",
+			"(node budget of 2 reached, 1 more entries not shown)",
+		)},
+		// Nothing to mark when everything fits
+		{[]string{"-node-budget", "12"}, lines(
+			"root",
+			"├── a",
+			"│   └── a1",
+			"│       └── x",
+			"├── b",
+			"│   ├── b1",
+			"│   │   └── y",
+			"│   └── b2",
+			"│       └── z",
+			"├── c",
+			"│   └── c1",
+			"│       └── w",
+			"└── top",
+		)},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, append(tt.args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	// Structured output only holds what fits, with the note on stderr
+	stdout, stderr, err := runDirtext(t, "-node-budget", "3", "-format", "paths-json", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(node budget of 3 reached, 9 more entries not shown)\n"; stderr != want {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+	if want := "[\n  \"a\",\n  \"b\",\n  \"c\"\n]\n"; stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+
+	if _, _, err := runDirtext(t, "-node-budget", "-1", root); err == nil {
+		t.Error("-node-budget -1 was accepted")
+	}
+}
+
+func TestDeepest(t *testing.T) {
+	tests := []struct {
+		entries []string
+		args    []string
+		want    string
+	}{
+		// Every file tied for deepest is kept
+		{[]string{"a/b/c/f1", "x/y/z/f2", "q/f3", "top"}, nil, lines(
+			"root",
+			"├── a",
+			"│   └── b",
+			"│       └── c",
+			"│           └── f1 (depth 4)",
+			"└── x",
+			"    └── y",
+			"        └── z",
+			"            └── f2 (depth 4)",
+		)},
+		// Empty directories don't count, however deep
+		{[]string{"a/b/f1", "e/e/e/e/e/", "top"}, nil, lines(
+			"root",
+			"└── a",
+			"    └── b",
+			"        └── f1 (depth 3)",
+		)},
+		{[]string{"a/b/f1", "top"}, []string{"-flat"}, lines(
+			"a",
+			"a/b",
+			"a/b/f1",
+		)},
+		{[]string{"only"}, nil, lines(
+			"root",
+			"└── only (depth 1)",
+		)},
+	}
+
+	for _, tt := range tests {
+		root := makeTree(t, tt.entries...)
+		args := append([]string{"-deepest"}, tt.args...)
+		if got := render(t, append(args, root)...); got != tt.want {
+			t.Errorf("%v %v:\ngot:\n%s\nwant:\n%s", tt.entries, tt.args, got, tt.want)
+		}
+	}
+
+	if _, _, err := runDirtext(t, "-deepest", "-show-depth", makeTree(t, "a")); err == nil {
+		t.Error("-deepest -show-depth was accepted")
+	}
+}
+
+func TestTreeSort(t *testing.T) {
+	root := makeTree(t,
+		"Banana", "Bdir/", "README", "Zed", "adir/A", "adir/Zsub/", "adir/asub/", "adir/b",
+		"apple", "readme", "Éclair", "éa",
+	)
+
+	// Directories first, then names ignoring case, with names equal but for
+	// case in byte order
+	want := lines(
+		"root",
+		"├── adir",
+		"│   ├── asub",
+		"│   ├── Zsub",
+		"│   ├── A",
+		"│   └── b",
+		"├── Bdir",
+		"├── apple",
+		"├── Banana",
+		"├── README",
+		"├── readme",
+		"├── Zed",
+		"├── éa",
+		"└── Éclair",
+	)
+	if got := render(t, "-tree-sort", root); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Byte order without it
+	if got, want := render(t, "-flat", root), lines(
+		"Banana", "Bdir", "README", "Zed", "adir", "adir/A", "adir/Zsub", "adir/asub", "adir/b",
+		"apple", "readme", "Éclair", "éa",
+	); got != want {
+		t.Errorf("-flat:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, args := range [][]string{
+		{"-sort", "size"},
+		{"-dirs-first"},
+		{"-files-first"},
+	} {
+		if _, _, err := runDirtext(t, append(append([]string{"-tree-sort"}, args...), root)...); err == nil {
+			t.Errorf("-tree-sort %v was accepted", args)
+		}
+	}
+}
+
+func TestSampleSeed(t *testing.T) {
+	var names []string
+	for i := 1; i <= 12; i++ {
+		names = append(names, fmt.Sprintf("f%d", i))
+	}
+
+	// The same names, created in opposite orders
+	root := makeTree(t, names...)
+	slices.Reverse(names)
+	reversed := makeTree(t, names...)
+
+	sample := func(root, seed string) string {
+		t.Helper()
+		return render(t, "-max-per-dir", "3", "-sample-seed", seed, root)
+	}
+
+	// The sample is kept in name order, and math/rand's seeded source never
+	// changes, so a seed picks the same entries everywhere
+	want := lines(
+		"root",
+		"├── f3",
+		"├── f8",
+		"├── f9",
+		"└── ... (9 more)",
+	)
+	for range 3 {
+		if got := sample(root, "7"); got != want {
+			t.Errorf("seed 7:\ngot:\n%s\nwant:\n%s", got, want)
+		}
+		if got := sample(reversed, "7"); got != want {
+			t.Errorf("seed 7, created in reverse:\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	}
+
+	if got := sample(root, "8"); got == want {
+		t.Errorf("seeds 7 and 8 picked the same entries:\n%s", got)
+	}
+
+	if _, _, err := runDirtext(t, "-sample-seed", "7", root); err == nil {
+		t.Error("-sample-seed without -max-per-dir was accepted")
+	}
+}
+
+func TestOrderFile(t *testing.T) {
+	root := makeTree(t, "README.md", "cmd/dirtext/blame.go", "cmd/dirtext/main.go", "cmd/dirtext/options.go", "docs/a.md", "go.mod", "z.txt")
+	order := filepath.Join(t.TempDir(), "order.txt")
+	writeFile(t, filepath.Dir(order), "order.txt", lines(
+		"# important first",
+		"go.mod",
+		"",
+		"cmd/dirtext/options.go",
+		"missing/file",
+		"cmd/dirtext/main.go",
+		"z.txt",
+	))
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// cmd moves up with options.go, the first entry listed beneath it
+		{nil, lines(
+			"root",
+			"├── go.mod",
+			"├── cmd",
+			"│   └── dirtext",
+			"│       ├── options.go",
+			"│       ├── main.go",
+			"│       └── blame.go",
+			"├── z.txt",
+			"├── README.md",
+			"└── docs",
+			"    └── a.md",
+		)},
+		// The rest keep the chosen sort order
+		{[]string{"-dirs-first"}, lines(
+			"root",
+			"├── go.mod",
+			"├── cmd",
+			"│   └── dirtext",
+			"│       ├── options.go",
+			"│       ├── main.go",
+			"│       └── blame.go",
+			"├── z.txt",
+			"├── docs",
+			"│   └── a.md",
+			"└── README.md",
+		)},
+		{[]string{"-flat"}, lines(
+			"go.mod",
+			"cmd",
+			"cmd/dirtext",
+			"cmd/dirtext/options.go",
+			"cmd/dirtext/main.go",
+			"cmd/dirtext/blame.go",
+			"z.txt",
+			"README.md",
+			"docs",
+			"docs/a.md",
+		)},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-order-file", order}, tt.args...)
+		if got := render(t, append(args, root)...); got != tt.want {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	// The order file is read like every other file
+	if opened := openedBy(t, "-order-file", order, root); !slices.Contains(opened, order) {
+		t.Errorf("-order-file wasn't opened through openReadOnly: %q", opened)
+	}
+
+	if _, _, err := runDirtext(t, "-order-file", filepath.Join(root, "none.txt"), root); err == nil {
+		t.Error("a missing -order-file was accepted")
+	}
+}
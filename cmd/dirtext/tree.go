@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Node is one entry of the scanned tree
+type Node struct {
+	// Name is the entry's base name
+	Name string
+
+	// Path is the entry's path relative to the scan root, using '/'
+	Path string
+
+	IsDir bool
+	Mode  fs.FileMode
+
+	// Size is the size in bytes as reported by lstat
+	Size int64
+
+	// ModTime is the modification time as reported by lstat
+	ModTime time.Time
+
+	// Hash is the hex SHA-256 of a file's contents, set by -hash
+	Hash string
+
+	// Status is the entry's -compare status: added, removed, changed or common
+	Status string
+
+	// TotalFiles and TotalSize count the files beneath a directory and
+	// their combined size, once computeTotals has run
+	TotalFiles int
+	TotalSize  int64
+
+	// LinkTarget is the symlink's target, set when -link-targets is used
+	LinkTarget string
+
+	// Notes are short annotations printed in parentheses after the name
+	Notes []string
+
+	// Collapsed marks directories whose contents aren't shown, such as
+	// -leaf-dirs
+	Collapsed bool
+
+	// Missing marks tracked files that don't exist on disk, so there is
+	// nothing to read
+	Missing bool
+
+	// Filtered marks directories excluded by -type-filter. They are kept in
+	// the tree only to hold their children and are never printed; text trees
+	// lift those children out of them with liftFiltered.
+	Filtered bool
+
+	Children []*Node
+
+	// Preview holds the first lines of the file, set by -preview
+	Preview []string
+
+	// More counts the children dropped by -max-per-dir
+	More int
+}
+
+// scanReport counts the work done by buildTree and collects the per-path
+// errors it skipped over
+type scanReport struct {
+	elapsed   time.Duration
+	dirsRead  int
+	cacheHits int
+	entries   int
+	errors    []scanError
+
+	// missing are the -require patterns no entry matched
+	missing []string
+
+	// collisions counts the sets of names found by -check-case-collisions
+	collisions int
+
+	// suspicious counts the files reported by -suspicious-mtime
+	suspicious int
+}
+
+// scanError is an entry that couldn't be read
+type scanError struct {
+	// Root is the scan root the path is relative to, set when several roots
+	// are scanned
+	Root  string `json:"root,omitempty"`
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// buildTree walks rootDir and returns the tree of visible entries, recording
+// how much work it did in report. .gitignore files are loaded through
+// ignoreFiles, and directory listings are reused from cache when it isn't nil.
+func buildTree(rootDir string, opts *Options, stderr io.Writer, ignoreFiles *gitignoreFiles, report *scanReport, cache *scanCache) (*Node, error) {
+	start := time.Now()
+	defer func() { report.elapsed = time.Since(start) }()
+
+	// Compile the command line filters
+	filter, err := newEntryFilter(opts, rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load gitignore patterns
+	ignorePatterns, err := ignoreFiles.load(rootDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "Warning: couldn't load .gitignore: %v\n", err)
+	}
+	ignores := newIgnoreCache(rootDir, ignorePatterns, ignoreFiles, stderr)
+
+	root := &Node{Name: filepath.Base(rootDir), IsDir: true, Mode: fs.ModeDir}
+
+	// Directories seen so far, keyed by relative path, so each entry can be
+	// attached to its parent
+	dirs := map[string]*Node{"": root}
+
+	// List directories from the scan cache if one is in use
+	readDir := os.ReadDir
+	if cache != nil {
+		readDir = cache.readDir
+	}
+
+	// skip records an error for an entry that couldn't be read when
+	// -errors-json is used, so the rest of the tree still renders; otherwise
+	// the error ends the scan
+	skip := func(path string, d fs.DirEntry, err error) error {
+		if opts.ErrorsJSON == "" || path == rootDir {
+			return err
+		}
+
+		relPath, _ := filepath.Rel(rootDir, path)
+		report.errors = append(report.errors, scanError{Path: filepath.ToSlash(relPath), Error: err.Error()})
+
+		if d != nil && d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+
+	// Walk the directory tree
+	err = walkDir(rootDir, readDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return skip(path, d, err)
+		}
+
+		// Skip the root directory itself
+		if path == rootDir {
+			report.dirsRead++
+			return nil
+		}
+		report.entries++
+
+		// Get relative path, always using '/' so gitignore patterns and
+		// printed paths behave the same on every platform
+		relPath, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		// Skip hidden files and directories (starting with .), except leaf
+		// directories
+		if filter.hidden(relPath, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Skip files/directories that match the gitignore patterns of their
+		// directory and its ancestors
+		if ignores.matcher(parentDir(relPath)).ignored(relPath, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Skip entries dropped by the command line filters
+		if filter.excluded(relPath, d.Type()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return skip(path, d, err)
+		}
+
+		node := &Node{
+			Name:    d.Name(),
+			Path:    relPath,
+			IsDir:   d.IsDir(),
+			Mode:    info.Mode(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+
+		// Directories excluded by the type filter are still descended into
+		// so their matching children are printed
+		node.Filtered = node.IsDir && filter.hidesDir(d.Type())
+
+		// Never reveal where a symlink leading out of the root points
+		escapes := opts.NoEscapeRoot && d.Type()&fs.ModeSymlink != 0 && escapesRoot(path, rootDir)
+		if escapes {
+			node.Notes = append(node.Notes, "outside root")
+		}
+
+		// Record the symlink target if requested
+		if opts.LinkTargets && d.Type()&fs.ModeSymlink != 0 && !escapes {
+			node.LinkTarget, err = linkTarget(path, rootDir, opts.RelativeLinks)
+			if err != nil {
+				return skip(path, d, err)
+			}
+		}
+
+		parent := dirs[parentDir(relPath)]
+		parent.Children = append(parent.Children, node)
+
+		// Show leaf directories such as node_modules without their contents
+		if node.IsDir && filter.isLeafDir(node.Name) {
+			node.Collapsed = true
+			return filepath.SkipDir
+		}
+
+		if node.IsDir {
+			dirs[relPath] = node
+			report.dirsRead++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("walking directory: %w", err)
+	}
+
+	// Drop directories left empty by the filters
+	filter.prune(root)
+
+	return root, nil
+}
+
+// visibleChildren returns the children of n that are printed, replacing
+// directories hidden by -type-filter with their own visible children. It is
+// used by the nested formats, which have no way to show a gap in the tree.
+func visibleChildren(n *Node) []*Node {
+	var children []*Node
+	for _, child := range n.Children {
+		if child.Filtered {
+			children = append(children, visibleChildren(child)...)
+		} else {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// Depth returns how deeply the node is nested: 0 for the root, 1 for its
+// children and so on
+func (n *Node) Depth() int {
+	if n.Path == "" {
+		return 0
+	}
+	return strings.Count(n.Path, "/") + 1
+}
+
+// writeScanErrors writes the errors as a JSON array of {path, error} objects
+func writeScanErrors(path string, errs []scanError) error {
+	if errs == nil {
+		errs = []scanError{}
+	}
+
+	data, err := json.MarshalIndent(errs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lockDir makes the directory unreadable until the test ends, skipping the
+// test where permissions aren't enforced, as for root
+func lockDir(t *testing.T, dir string) {
+	t.Helper()
+
+	if err := os.Chmod(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0o755) })
+	if _, err := os.ReadDir(dir); err == nil {
+		t.Skip("directory permissions aren't enforced")
+	}
+}
+
+func TestErrorsJSON(t *testing.T) {
+	root := makeTree(t, "a.txt", "locked/secret", "open/b.txt")
+	lockDir(t, filepath.Join(root, "locked"))
+
+	readErrors := func(path string) []scanError {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var errs []scanError
+		if err := json.Unmarshal(data, &errs); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, data)
+		}
+		return errs
+	}
+
+	// Without -errors-json the scan fails
+	if _, _, err := runDirtext(t, root); err == nil {
+		t.Error("scanning an unreadable directory succeeded")
+	}
+
+	// With it, the rest of the tree renders and the error is recorded
+	errorsFile := filepath.Join(t.TempDir(), "errors.json")
+	stdout, stderr, err := runDirtext(t, "-errors-json", errorsFile, root)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, stderr)
+	}
+	want := lines(
+		"root",
+		"├── a.txt",
+		"├── locked",
+		"└── open",
+		"    └── b.txt",
+	)
+	if stdout != want {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout, want)
+	}
+	errs := readErrors(errorsFile)
+	if len(errs) != 1 || errs[0].Path != "locked" || errs[0].Root != "" || !strings.Contains(errs[0].Error, "permission denied") {
+		t.Errorf("errors: %+v", errs)
+	}
+
+	// With several roots each error names its root, and a clean scan writes
+	// an empty array
+	clean := makeTree(t, "c.txt")
+	if _, stderr, err := runDirtext(t, "-errors-json", errorsFile, clean, root); err != nil {
+		t.Fatalf("%v\n%s", err, stderr)
+	}
+	errs = readErrors(errorsFile)
+	if len(errs) != 1 || errs[0].Root != root || errs[0].Path != "locked" {
+		t.Errorf("several roots: %+v", errs)
+	}
+
+	render(t, "-errors-json", errorsFile, clean)
+	if errs := readErrors(errorsFile); !reflect.DeepEqual(errs, []scanError{}) {
+		t.Errorf("clean scan: %+v", errs)
+	}
+}
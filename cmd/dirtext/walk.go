@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// walkDir behaves like filepath.WalkDir, but reads each directory with
+// readDir so listings can come from the scan cache. readDir must return the
+// entries sorted by name.
+func walkDir(root string, readDir func(string) ([]fs.DirEntry, error), fn fs.WalkDirFunc) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkEntry(root, fs.FileInfoToDirEntry(info), readDir, fn)
+	}
+
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkEntry visits path and, if it is a directory, everything beneath it
+func walkEntry(path string, d fs.DirEntry, readDir func(string) ([]fs.DirEntry, error), fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			// Successfully skipped directory
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := readDir(path)
+	if err != nil {
+		// Second call, to report the ReadDir error
+		err = fn(path, d, err)
+		if err != nil {
+			if err == filepath.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		if err := walkEntry(filepath.Join(path, entry.Name()), entry, readDir, fn); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+
+	return nil
+}
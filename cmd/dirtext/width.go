@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// wideRanges are the code point ranges that terminals draw two columns wide:
+// the East Asian Wide and Fullwidth characters plus the common emoji blocks
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},   // Hangul Jamo initial consonants
+	{0x2E80, 0x303E},   // CJK radicals, Kangxi radicals, CJK symbols
+	{0x3041, 0x33FF},   // Hiragana, Katakana, Bopomofo, CJK compatibility
+	{0x3400, 0x4DBF},   // CJK unified ideographs extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi syllables and radicals
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE30, 0xFE4F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // Fullwidth forms
+	{0xFFE0, 0xFFE6},   // Fullwidth signs
+	{0x1F300, 0x1F64F}, // Miscellaneous symbols and pictographs, emoticons
+	{0x1F900, 0x1F9FF}, // Supplemental symbols and pictographs
+	{0x20000, 0x3FFFD}, // CJK unified ideographs extensions B and beyond
+}
+
+// runeWidth returns the number of terminal columns r occupies: 0 for
+// combining marks, format characters (such as zero-width joiners) and
+// controls, 2 for wide characters and 1 otherwise
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf, unicode.Cc) {
+		return 0
+	}
+
+	for _, wide := range wideRanges {
+		if r >= wide.lo && r <= wide.hi {
+			return 2
+		}
+	}
+
+	return 1
+}
+
+// displayWidth returns the number of terminal columns s occupies. Anything
+// that pads or aligns text next to file names must measure it with this
+// rather than len or utf8.RuneCountInString. ANSI escape sequences, such as
+// colors, take up no columns and are skipped.
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range stripANSI(s) {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// stripANSI removes ANSI escape sequences from s: CSI sequences such as the
+// color code "\x1b[31m", which end in a byte from '@' to '~', and OSC
+// sequences such as hyperlinks, which end in BEL or ESC \
+func stripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != 0x1b || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch s[i+1] {
+		case '[':
+			// Skip the parameters up to and including the final byte
+			i += 2
+			for i < len(s) && (s[i] < '@' || s[i] > '~') {
+				i++
+			}
+		case ']':
+			// Skip up to the BEL or string terminator
+			i += 2
+			for i < len(s) && s[i] != '\a' && !(s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\') {
+				i++
+			}
+			if i < len(s) && s[i] == 0x1b {
+				i++
+			}
+		default:
+			// A two-byte escape
+			i++
+		}
+	}
+	return b.String()
+}
+
+// writeTable writes the rows as columns separated by columnGap, padding
+// every cell but the last of its row to the widest cell of its column in
+// terminal columns. text/tabwriter counts runes, which misaligns the columns
+// after wide characters.
+func writeTable(w io.Writer, rows [][]string) error {
+	var widths []int
+	for _, row := range rows {
+		for col, cell := range row {
+			if col == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[col] = max(widths[col], displayWidth(cell))
+		}
+	}
+
+	for _, row := range rows {
+		var b strings.Builder
+		for col, cell := range row {
+			b.WriteString(cell)
+			if col < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[col]-displayWidth(cell)) + columnGap)
+			}
+		}
+		if _, err := fmt.Fprintln(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
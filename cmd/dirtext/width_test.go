@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"main.go", 7},
+		{"日本語.txt", 10},
+		{"한국어", 6},
+		{"ｆｕｌｌ", 8},
+		{"e\u0301cole", 5},    // combining acute accent
+		{"a\u200db", 2},       // zero-width joiner
+		{"\U0001F600.png", 6}, // emoji
+		{"├── 文件", 8},
+	}
+
+	for _, tt := range tests {
+		if got := displayWidth(tt.s); got != tt.want {
+			t.Errorf("displayWidth(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestWideNamesAlign(t *testing.T) {
+	root := makeTree(t, "a.txt", "日本語.txt", "sub/文件", "école")
+
+	// Every line of a box has the same width in columns
+	boxed := render(t, "-boxed", "-deterministic", root)
+	rows := strings.Split(strings.TrimSuffix(boxed, "\n"), "\n")
+	for _, row := range rows {
+		if displayWidth(row) != displayWidth(rows[0]) {
+			t.Errorf("box rows differ in width:\n%s", boxed)
+			break
+		}
+	}
+	want := lines(
+		"┌─ root ─────────┐",
+		"│ ├── a.txt      │",
+		"│ ├── e\u0301cole      │",
+		"│ ├── sub        │",
+		"│ │   └── 文件   │",
+		"│ └── 日本語.txt │",
+		"└────────────────┘",
+	)
+	if boxed != want {
+		t.Errorf("-boxed:\ngot:\n%s\nwant:\n%s", boxed, want)
+	}
+
+	// Annotations after wide names start in the same column
+	flat := makeTree(t, "a.txt", "日本語.txt", "文件", "e\u0301cole")
+	aligned := render(t, "-align-columns", "-hash", flat)
+	var columns []int
+	for _, line := range strings.Split(strings.TrimSuffix(aligned, "\n"), "\n") {
+		if i := strings.Index(line, "(sha256:"); i >= 0 {
+			columns = append(columns, displayWidth(line[:i]))
+		}
+	}
+	if len(columns) != 4 {
+		t.Fatalf("-align-columns -hash: want 4 hashed files, got:\n%s", aligned)
+	}
+	for _, c := range columns[1:] {
+		if c != columns[0] {
+			t.Errorf("-align-columns -hash: hashes start in different columns:\n%s", aligned)
+			break
+		}
+	}
+}
+
+func TestWideNamesTable(t *testing.T) {
+	root := makeTree(t, "b/y", "日本語/x", "e\u0301cole/z")
+
+	// text/tabwriter would count the runes of these paths
+	want := lines(
+		"PATH    ENTRIES  FILES  SIZE",
+		".       3        3      0 B",
+		"b       1        1      0 B",
+		"e\u0301cole   1        1      0 B",
+		"日本語  1        1      0 B",
+	)
+	if got := render(t, "-dir-summary", root); got != want {
+		t.Errorf("-dir-summary:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"plain", "plain"},
+		{"\x1b[31mred\x1b[0m", "red"},
+		{"\x1b[1;38;5;208mbold\x1b[m", "bold"},
+		{"\x1b]8;;https://example.com\x07link\x1b]8;;\x07", "link"},
+		{"\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"\x1b7saved\x1b8", "saved"},
+		{"\x1b[31m日本語\x1b[0m", "日本語"},
+		// An unfinished sequence is dropped, a lone ESC at the end kept
+		{"a\x1b[31", "a"},
+		{"a\x1b", "a\x1b"},
+	}
+
+	for _, tt := range tests {
+		if got := stripANSI(tt.s); got != tt.want {
+			t.Errorf("stripANSI(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+
+	if got := displayWidth("\x1b[32m├── 文件\x1b[0m"); got != 8 {
+		t.Errorf("width with colors = %d, want 8", got)
+	}
+}
+
+func TestColoredPreviewAligns(t *testing.T) {
+	root := makeTree(t)
+	writeFile(t, root, "log.txt", "\x1b[31merror\x1b[0m: plain\n")
+
+	// The color codes in the preview don't widen the box
+	boxed := render(t, "-boxed", "-deterministic", "-preview", "1", root)
+	want := lines(
+		"┌─ root ─────────────┐",
+		"│ └── log.txt        │",
+		"│       \x1b[31merror\x1b[0m: plain │",
+		"└────────────────────┘",
+	)
+	if boxed != want {
+		t.Errorf("got:\n%s\nwant:\n%s", boxed, want)
+	}
+}